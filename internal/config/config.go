@@ -6,22 +6,82 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+type Encoding struct {
+	Codec       string
+	CRF         int
+	Preset      string
+	PixelFormat string
+	GOP         int
+	Tune        string
+	SlideTune   string
+	Bitrate     int64
+}
+
 type Data struct {
-	RecordingDir string
-	OutputFile   string
-	WorkingDir   string
-	ThreadCount  string
-	Width        int64
-	Height       int64
+	RecordingDir       string
+	OutputFile         string
+	WorkingDir         string
+	ThreadCount        string
+	Width              int64
+	Height             int64
+	AudioFormat        string
+	AudioOnly          bool
+	Encoding           Encoding
+	MaxSize            int64
+	TargetBitrate      int64
+	MinBitrate         int64
+	Renditions         []Rendition
+	SegmentTime        int
+	FastStart          bool
+	Fragmented         bool
+	MKVSubtitleCodec   string
+	Burn               BurnStyle
+	SidecarCaptions    bool
+	CaptionTitle       string
+	DefaultCaption     string
+	ForcedCaption      string
+	CaptionOrder       string
+	CaptionOffset      float64
+	CaptionCleanup     bool
+	CaptionMaxLine     int
+	CaptionMaxDuration float64
+	ChatTrack          bool
+	ChatAnonymize      bool
+	ChatRoles          string
+	ChatPanel          bool
+	ChatPanelWidth     int64
+	ChatTranscript     []string
+	SlidesPDF          bool
+	SlideImages        string
+	SearchIndex        bool
+	Poster             string
+	PosterTime         float64
+	ThumbnailInterval  int
+	ThumbnailWidth     int
+}
+
+type BurnStyle struct {
+	Locale   string
+	Font     string
+	Size     int
+	Outline  int
+	Box      bool
+	Position string
 }
 
 func (c *Data) LoadConfig() error {
 	showVersion := false
+	maxSize := ""
+	targetBitrate := ""
+	minBitrate := ""
+	renditions := ""
+	chatTranscript := ""
 	flag.StringVar(&c.RecordingDir, "i", "",
-		"Specify recording directory.")
+		"Specify recording directory (presentation, video or podcast format).")
 	flag.StringVar(&c.OutputFile, "o", "",
 		"Specify output file. Default is video.mp4 in the recording dir.")
 	flag.BoolVar(&showVersion, "v", false,
@@ -32,6 +92,92 @@ func (c *Data) LoadConfig() error {
 		"Browser width, default 800.")
 	flag.Int64Var(&c.Height, "h", 600,
 		"Browser height, default 600.")
+	flag.StringVar(&c.AudioFormat, "audio", "",
+		"Additionally export the audio track with chapters (mp3, m4a or opus).")
+	flag.StringVar(&c.Encoding.Codec, "codec", "",
+		"Video codec (h264, h265, vp9 or av1), default h264 for mp4 and vp9 for webm.")
+	flag.IntVar(&c.Encoding.CRF, "crf", -1,
+		"Constant rate factor, default depends on the codec (h264 22, h265 26, vp9 33, av1 35).")
+	flag.StringVar(&c.Encoding.Preset, "preset", "",
+		"Encoder preset, default ultrafast for h264/h265 and 8 for vp9 (cpu-used) and av1.")
+	flag.StringVar(&c.Encoding.PixelFormat, "pix-fmt", "yuv420p",
+		"Pixel format, default yuv420p.")
+	flag.IntVar(&c.Encoding.GOP, "gop", 0,
+		"Keyframe interval in frames, default is the encoder default.")
+	flag.StringVar(&c.Encoding.Tune, "tune", "",
+		"Encoder tune for all encodes (e.g. film, animation, stillimage).")
+	flag.StringVar(&c.Encoding.SlideTune, "slide-tune", "stillimage",
+		"Encoder tune for the rendered slides, default stillimage.")
+	flag.StringVar(&maxSize, "max-size", "",
+		"Maximum output file size (e.g. 500M), enables a two-pass encode of the output.")
+	flag.StringVar(&targetBitrate, "target-bitrate", "",
+		"Target video bitrate (e.g. 1500k), enables a two-pass encode of file outputs, for HLS and DASH it is the bitrate of the highest rendition.")
+	flag.StringVar(&minBitrate, "min-bitrate", "150k",
+		"Minimum video bitrate accepted for -max-size, default 150k.")
+	flag.StringVar(&renditions, "renditions", "",
+		"Encode several outputs from one render, e.g. 1080:h264:mp4,720,480:vp9:webm (HEIGHT[:CODEC[:CONTAINER]]).")
+	flag.IntVar(&c.SegmentTime, "segment-time", 6,
+		"Segment duration in seconds for hls and dash outputs, default 6.")
+	flag.BoolVar(&c.FastStart, "faststart", false,
+		"Move the mp4 index (moov atom) to the start of the file so playback can start before the download finished.")
+	flag.BoolVar(&c.Fragmented, "fragmented", false,
+		"Write the mp4 as fragmented mp4 (fMP4).")
+	flag.StringVar(&c.MKVSubtitleCodec, "mkv-subtitles", "webvtt",
+		"Subtitle codec for mkv outputs (webvtt, srt or ass), default webvtt.")
+	flag.StringVar(&c.Burn.Locale, "burn-captions", "",
+		"Burn the captions of the given locale (e.g. de) into the video.")
+	flag.StringVar(&c.Burn.Font, "burn-font", "Noto Sans",
+		"Font of the burned-in captions, default Noto Sans.")
+	flag.IntVar(&c.Burn.Size, "burn-size", 0,
+		"Font size of the burned-in captions in pixels, default 5% of the video height.")
+	flag.IntVar(&c.Burn.Outline, "burn-outline", 2,
+		"Outline width of the burned-in captions in pixels, default 2.")
+	flag.BoolVar(&c.Burn.Box, "burn-box", false,
+		"Draw a background box behind the burned-in captions.")
+	flag.StringVar(&c.Burn.Position, "burn-position", "bottom",
+		"Position of the burned-in captions (top or bottom), default bottom.")
+	flag.BoolVar(&c.SidecarCaptions, "sidecar-captions", false,
+		"Write the captions as <output>.<lang>.vtt and <output>.<lang>.srt next to the output.")
+	flag.StringVar(&c.CaptionTitle, "caption-title", "local",
+		"Caption track titles in the own language (local, e.g. Deutsch) or in english (english, e.g. German).")
+	flag.StringVar(&c.DefaultCaption, "default-caption", "",
+		"Locale of the default caption track, meeting uses the meta_language parameter of the meeting.")
+	flag.StringVar(&c.ForcedCaption, "forced-caption", "",
+		"Locale of the caption track which is marked as forced.")
+	flag.StringVar(&c.CaptionOrder, "caption-order", "",
+		"Order of the caption tracks as list of locales (e.g. de,en), other tracks follow.")
+	flag.Float64Var(&c.CaptionOffset, "caption-offset", 0,
+		"Shift all captions by the given seconds (negative values show them earlier).")
+	flag.BoolVar(&c.CaptionCleanup, "caption-cleanup", false,
+		"Drop empty and duplicate caption cues and merge overlapping ones.")
+	flag.IntVar(&c.CaptionMaxLine, "caption-max-line", 0,
+		"Rewrap caption lines longer than the given characters.")
+	flag.Float64Var(&c.CaptionMaxDuration, "caption-max-duration", 0,
+		"Cap the duration of a caption cue to the given seconds.")
+	flag.BoolVar(&c.ChatTrack, "chat-track", false,
+		"Add the public chat as subtitle track (\"Name: message\").")
+	flag.BoolVar(&c.ChatAnonymize, "chat-anonymize", false,
+		"Replace the chat names with Participant 1, Participant 2, ...")
+	flag.StringVar(&c.ChatRoles, "chat-roles", "all",
+		"Chat messages to include: all, moderator (only moderators) or viewer (no moderators), default all.")
+	flag.BoolVar(&c.ChatPanel, "chat-panel", false,
+		"Show the public chat as panel next to the presentation and webcams.")
+	flag.Int64Var(&c.ChatPanelWidth, "chat-panel-width", 320,
+		"Width of the chat panel in pixels (even, at least 100), default 320.")
+	flag.BoolVar(&c.SlidesPDF, "slides-pdf", false,
+		"Write the slides with their annotations as <output>.slides.pdf next to the output.")
+	flag.StringVar(&c.SlideImages, "slide-images", "",
+		"Write an image (png or jpg) of every slide, drawing and zoom change to <output>.slides/ with an index.json.")
+	flag.BoolVar(&c.SearchIndex, "search-index", false,
+		"Write the slide texts with the times they are shown as <output>.search.json next to the output.")
+	flag.StringVar(&c.Poster, "poster", "",
+		"Poster image embedded as cover art and written as <output>.jpg: first (slide), most (shown slide), title (card) or a time ([hh:]mm:ss or seconds).")
+	flag.IntVar(&c.ThumbnailInterval, "thumbnails", 0,
+		"Write seek preview sprite sheets with a thumbnails.vtt to <output>.thumbnails/, one thumbnail every given seconds.")
+	flag.IntVar(&c.ThumbnailWidth, "thumbnail-width", 160,
+		"Width of the seek preview thumbnails in pixels, default 160.")
+	flag.StringVar(&chatTranscript, "chat-transcript", "",
+		"Write the chat as <output>.chat.<format> next to the output, comma separated list of html, txt and json.")
 	flag.Usage = func() {
 		fmt.Println("Usage:")
 		fmt.Println("bbb-video-converter -v")
@@ -57,8 +203,86 @@ func (c *Data) LoadConfig() error {
 	} else if !strings.HasPrefix(c.OutputFile, string(os.PathSeparator)) {
 		c.OutputFile = filepath.Join(c.RecordingDir, c.OutputFile)
 	}
-	if !strings.HasSuffix(c.OutputFile, ".mp4") && !strings.HasSuffix(c.OutputFile, ".webm") {
-		return errors.New("output file can only be an mp4 or webm (the file extension must match)")
+	for _, format := range []string{"mp3", "m4a", "opus"} {
+		if strings.HasSuffix(c.OutputFile, "."+format) {
+			c.AudioOnly = true
+			c.AudioFormat = format
+		}
+	}
+	if !c.AudioOnly && c.Container() != "mp4" && c.Container() != "webm" && c.Container() != "mkv" && !c.IsStreaming() {
+		return errors.New("output file can only be an mp4, webm, mkv, m3u8, mpd, mp3, m4a or opus (the file extension must match)")
+	}
+	if c.AudioFormat != "" && c.AudioFormat != "mp3" && c.AudioFormat != "m4a" && c.AudioFormat != "opus" {
+		return errors.New("audio format can only be mp3, m4a or opus (" + c.AudioFormat + ")")
+	}
+	c.MaxSize, err = parseUnit(maxSize)
+	if err != nil {
+		return errors.New("max size is not valid (" + maxSize + ")")
+	}
+	c.TargetBitrate, err = parseUnit(targetBitrate)
+	if err != nil {
+		return errors.New("target bitrate is not valid (" + targetBitrate + ")")
+	}
+	c.MinBitrate, err = parseUnit(minBitrate)
+	if err != nil {
+		return errors.New("min bitrate is not valid (" + minBitrate + ")")
+	}
+	if c.MaxSize > 0 && c.TargetBitrate > 0 {
+		return errors.New("max size and target bitrate can not be used together")
+	}
+	if c.IsStreaming() && (c.MaxSize > 0 || c.SegmentTime < 1) {
+		return errors.New("hls and dash outputs need a segment duration of at least 1s and can not be limited by -max-size")
+	}
+	if c.FastStart && c.Fragmented {
+		return errors.New("faststart and fragmented can not be used together, fragmented mp4 is already streamable")
+	}
+	if c.MKVSubtitleCodec != "webvtt" && c.MKVSubtitleCodec != "srt" && c.MKVSubtitleCodec != "ass" {
+		return errors.New("mkv subtitles can only be webvtt, srt or ass (" + c.MKVSubtitleCodec + ")")
+	}
+	if c.Burn.Position != "top" && c.Burn.Position != "bottom" {
+		return errors.New("burn position can only be top or bottom (" + c.Burn.Position + ")")
+	}
+	if c.CaptionTitle != "local" && c.CaptionTitle != "english" {
+		return errors.New("caption title can only be local or english (" + c.CaptionTitle + ")")
+	}
+	if c.ChatRoles != "all" && c.ChatRoles != "moderator" && c.ChatRoles != "viewer" {
+		return errors.New("chat roles can only be all, moderator or viewer (" + c.ChatRoles + ")")
+	}
+	if c.SlideImages != "" && c.SlideImages != "png" && c.SlideImages != "jpg" {
+		return errors.New("slide images can only be png or jpg (" + c.SlideImages + ")")
+	}
+	if c.Poster != "" && c.Poster != "first" && c.Poster != "most" && c.Poster != "title" {
+		c.PosterTime, err = parseTime(c.Poster)
+		if err != nil {
+			return errors.New("poster can only be first, most, title or a time (" + c.Poster + ")")
+		}
+	}
+	if c.ThumbnailInterval < 0 || (c.ThumbnailInterval > 0 && c.ThumbnailWidth < 16) {
+		return errors.New("thumbnails need a positive interval and a width of at least 16 pixels")
+	}
+	if c.ChatPanel && c.ChatPanelWidth < 100 {
+		return errors.New("chat panel width must be at least 100 pixels (" + fmt.Sprint(c.ChatPanelWidth) + ")")
+	}
+	if c.ChatPanel && c.ChatPanelWidth%2 != 0 {
+		return errors.New("chat panel width must be even, the yuv420p encode needs an even video width (" + fmt.Sprint(c.ChatPanelWidth) + ")")
+	}
+	if chatTranscript != "" {
+		for _, format := range strings.Split(chatTranscript, ",") {
+			format = strings.TrimSpace(format)
+			if format != "html" && format != "txt" && format != "json" {
+				return errors.New("chat transcript can only be html, txt or json (" + format + ")")
+			}
+			c.ChatTranscript = append(c.ChatTranscript, format)
+		}
+	}
+	renditionEncoding := c.Encoding
+	err = c.Encoding.applyDefaults(strings.HasSuffix(c.OutputFile, ".webm"))
+	if err != nil {
+		return err
+	}
+	err = c.parseRenditions(renditions, renditionEncoding)
+	if err != nil {
+		return err
 	}
 	outDir := filepath.Dir(c.OutputFile)
 	dirInfo, err := os.Stat(outDir)
@@ -72,3 +296,67 @@ func (c *Data) LoadConfig() error {
 	}
 	return nil
 }
+
+func (e *Encoding) applyDefaults(webm bool) error {
+	if e.Codec == "" {
+		e.Codec = "h264"
+		if webm {
+			e.Codec = "vp9"
+		}
+	}
+	defaultCrf := map[string]int{"h264": 22, "h265": 26, "vp9": 33, "av1": 35}
+	crf, ok := defaultCrf[e.Codec]
+	if !ok {
+		return errors.New("codec can only be h264, h265, vp9 or av1 (" + e.Codec + ")")
+	}
+	if webm && e.Codec != "vp9" && e.Codec != "av1" {
+		return errors.New("webm output can only be encoded with vp9 or av1")
+	}
+	if e.CRF < 0 {
+		e.CRF = crf
+	}
+	if e.Preset == "" {
+		e.Preset = "ultrafast"
+		if e.Codec == "vp9" || e.Codec == "av1" {
+			e.Preset = "8"
+		}
+	}
+	return nil
+}
+
+// parseUnit parses values like 500M or 1500k into their absolute value (k=10^3, M=10^6, G=10^9).
+func parseUnit(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		multiplier = 1000
+	case "m":
+		multiplier = 1000 * 1000
+	case "g":
+		multiplier = 1000 * 1000 * 1000
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		return 0, errors.New("invalid value")
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// parseTime parses a time given as seconds or as [hh:]mm:ss.
+func parseTime(value string) (float64, error) {
+	seconds := 0.0
+	for _, part := range strings.Split(value, ":") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0, errors.New("invalid time")
+		}
+		seconds = seconds*60 + number
+	}
+	return seconds, nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultLadder is used for hls and dash outputs without -renditions.
+const defaultLadder = "720,480,360"
+
+type Rendition struct {
+	Height     int64
+	Encoding   Encoding
+	OutputFile string
+}
+
+// parseRenditions parses the -renditions list (HEIGHT[:CODEC[:CONTAINER]],...) into renditions next to the output file.
+// Without a list the output file itself is the only rendition in the original resolution.
+func (c *Data) parseRenditions(list string, encoding Encoding) error {
+	if list == "" && c.IsStreaming() {
+		list = defaultLadder
+	}
+	if list == "" {
+		c.Renditions = []Rendition{{Height: 0, Encoding: c.Encoding, OutputFile: c.OutputFile}}
+		return nil
+	}
+	base := strings.TrimSuffix(c.OutputFile, filepath.Ext(c.OutputFile))
+	container := strings.TrimPrefix(filepath.Ext(c.OutputFile), ".")
+	outputs := map[string]bool{}
+	for _, entry := range strings.Split(list, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		height, err := strconv.ParseInt(strings.TrimSuffix(parts[0], "p"), 10, 64)
+		if err != nil || height <= 0 || height%2 == 1 {
+			return errors.New("rendition height must be an even number (" + entry + ")")
+		}
+		rendition := Rendition{Height: height, Encoding: encoding}
+		if len(parts) > 1 && parts[1] != "" {
+			rendition.Encoding.Codec = parts[1]
+		}
+		renditionContainer := container
+		if len(parts) > 2 && parts[2] != "" {
+			renditionContainer = parts[2]
+		}
+		if c.IsStreaming() && renditionContainer != container {
+			return errors.New("hls and dash renditions can not use another container (" + entry + ")")
+		}
+		if renditionContainer != "mp4" && renditionContainer != "webm" && renditionContainer != "mkv" && renditionContainer != container {
+			return errors.New("rendition container can only be mp4, webm or mkv (" + entry + ")")
+		}
+		err = rendition.Encoding.applyDefaults(renditionContainer == "webm")
+		if err != nil {
+			return err
+		}
+		if renditionContainer == "m3u8" && rendition.Encoding.Codec == "vp9" {
+			return errors.New("hls renditions can only be encoded with h264, h265 or av1 (" + entry + ")")
+		}
+		rendition.OutputFile = base + "_" + fmt.Sprint(height) + "p." + renditionContainer
+		if outputs[rendition.OutputFile] {
+			return errors.New("rendition is defined twice (" + entry + ")")
+		}
+		outputs[rendition.OutputFile] = true
+		c.Renditions = append(c.Renditions, rendition)
+	}
+	return nil
+}
+
+// IsStreaming reports whether the output is a segmented hls (m3u8) or dash (mpd) stream.
+func (c *Data) IsStreaming() bool {
+	return c.Container() == "m3u8" || c.Container() == "mpd"
+}
+
+// Container returns the container of the output file (its extension without the dot).
+func (c *Data) Container() string {
+	return strings.TrimPrefix(filepath.Ext(c.OutputFile), ".")
+}
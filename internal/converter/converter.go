@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +36,54 @@ func (c *Converter) Run(config config.Data) error {
 	if err != nil {
 		return err
 	}
+	format, err := presentation.DetectFormat(config)
+	if err != nil {
+		return err
+	}
+	log.Println("Detected recording format: " + format.Version)
+	if format.Warning != "" {
+		log.Println("Warning: " + format.Warning)
+	}
+	var media modules.Video
+	if !format.HasSlides() {
+		media, err = modules.GetRecordingMedia(config, format.Playback)
+		if err != nil {
+			return err
+		}
+		if duration <= 0 {
+			duration = int(media.Duration)
+		}
+	}
+	if config.AudioOnly {
+		audioSource := media
+		if format.HasSlides() {
+			audioSource, err = modules.GetWebcamVideos(config, duration)
+			if err != nil {
+				return err
+			}
+			audioSource = mixDeskshareAudio(audioSource, config)
+		}
+		posterFile, err := createPoster(config, duration, audioSource)
+		if err != nil {
+			return err
+		}
+		err = exportAudio(config, duration, audioSource, posterFile)
+		if err != nil {
+			return err
+		}
+		return writeDocuments(config, duration, format)
+	}
+	// Fail before rendering if the max size can not be met.
+	bitrates := make([]int64, len(config.Renditions))
+	for i, rendition := range config.Renditions {
+		bitrates[i], err = modules.TargetVideoBitrate(config, rendition, duration)
+		if err != nil {
+			return err
+		}
+	}
+	if !format.HasSlides() {
+		return convertMedia(config, duration, format, media, bitrates)
+	}
 	var wg sync.WaitGroup
 	var webcamVideo modules.Video
 	var presentationVideo modules.Video
@@ -42,19 +91,32 @@ func (c *Converter) Run(config config.Data) error {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		webcamVideo, _ = modules.GetWebcamVideos(config, duration)
+		var err error
+		webcamVideo, err = modules.GetWebcamVideos(config, duration)
+		if err == nil {
+			webcamVideo = mixDeskshareAudio(webcamVideo, config)
+		}
 	}()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		presentationVideo = presentation.CreatePresentationVideo(config, duration)
+		presentationVideo = presentation.CreatePresentationVideo(config, duration, format)
 	}()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		captions, _ = modules.CreateCaptions(config)
+		if config.ChatTrack {
+			chatCaption, err := createChatCaption(config, duration)
+			if err != nil {
+				log.Println("Could not create the chat track: " + err.Error())
+				return
+			}
+			captions = append(captions, chatCaption)
+		}
 	}()
 	wg.Wait()
+	captions = modules.ArrangeCaptions(captions, config)
 	start := time.Now()
 	fullVideo, err := modules.CombinePresentationWithWebcams(presentationVideo, webcamVideo, config)
 	if err != nil {
@@ -62,29 +124,161 @@ func (c *Converter) Run(config config.Data) error {
 	}
 	end := time.Now().Sub(start)
 	log.Println("Combine presentation with webcam video took: " + fmt.Sprint(end))
+	if config.Burn.Locale != "" {
+		start = time.Now()
+		fullVideo, err = modules.BurnCaption(fullVideo, captions, presentationVideo, webcamVideo, config)
+		if err != nil {
+			return err
+		}
+		end = time.Now().Sub(start)
+		log.Println("Burning in captions took: " + fmt.Sprint(end))
+	}
+	if config.ChatPanel {
+		fullVideo, err = addChatPanel(fullVideo, config, duration)
+		if err != nil {
+			return err
+		}
+	}
+	return writeOutputs(config, duration, format, fullVideo, webcamVideo, captions, bitrates)
+}
 
-	if len(captions) > 0 {
-		err = modules.AddCaption(captions, config, fullVideo)
+// convertMedia repackages the media of a video or podcast recording, podcasts are shown with the title card.
+func convertMedia(config config.Data, duration int, format presentation.RecordingFormat, media modules.Video, bitrates []int64) error {
+	captions, _ := modules.CreateCaptions(config)
+	if config.ChatTrack {
+		chatCaption, err := createChatCaption(config, duration)
+		if err != nil {
+			log.Println("Could not create the chat track: " + err.Error())
+		} else {
+			captions = append(captions, chatCaption)
+		}
+	}
+	captions = modules.ArrangeCaptions(captions, config)
+	fullVideo := media
+	if media.IsOnlyAudio {
+		start := time.Now()
+		titleConfig := config
+		titleConfig.Poster = "title"
+		titleCard, err := presentation.CreatePoster(titleConfig, duration, modules.Video{})
 		if err != nil {
-			// Todo: should we really exit here if the caption thrown an error ?
 			return err
 		}
-		log.Println("Added caption data to video")
+		fullVideo, err = modules.CreateStillVideo(titleCard, media, config)
+		if err != nil {
+			return err
+		}
+		end := time.Now().Sub(start)
+		log.Println("Creating the podcast video took: " + fmt.Sprint(end))
 	}
-	if strings.HasSuffix(config.OutputFile, ".webm") {
-		err = modules.ProcessToEndExtension(fullVideo, config)
+	if config.Burn.Locale != "" {
+		start := time.Now()
+		var err error
+		fullVideo, err = modules.BurnCaption(fullVideo, captions, modules.Video{}, modules.Video{}, config)
 		if err != nil {
 			return err
 		}
+		end := time.Now().Sub(start)
+		log.Println("Burning in captions took: " + fmt.Sprint(end))
+	}
+	if config.ChatPanel {
+		log.Println("Skipping the chat panel, the " + format.Playback + " format is already rendered")
+	}
+	return writeOutputs(config, duration, format, fullVideo, media, captions, bitrates)
+}
+
+// writeOutputs writes the rendered video into all configured outputs, the audio export uses the audio source.
+func writeOutputs(config config.Data, duration int, format presentation.RecordingFormat, fullVideo modules.Video, audioSource modules.Video, captions []modules.Caption, bitrates []int64) error {
+	posterFile, err := createPoster(config, duration, fullVideo)
+	if err != nil {
+		return err
+	}
+	if config.ThumbnailInterval > 0 {
+		start := time.Now()
+		err = modules.WriteThumbnails(fullVideo, config, duration)
+		if err != nil {
+			return err
+		}
+		end := time.Now().Sub(start)
+		log.Println("Seek preview thumbnails took: " + fmt.Sprint(end))
+	}
+	config.Renditions, bitrates = capRenditions(config, bitrates, fullVideo)
+	if config.Container() == "mpd" {
+		err = modules.WriteDASH(fullVideo, captions, config, bitrates)
 	} else {
-		err = copyFile(fullVideo.VideoPath, config.OutputFile)
+		err = writeRenditions(fullVideo, config, bitrates, captions, writeOutputMetadata(config, duration), posterFile)
+	}
+	if err != nil {
+		return err
+	}
+	if config.Container() == "m3u8" {
+		err = modules.WriteHLSMaster(fullVideo, captions, config, duration)
+		if err != nil {
+			return err
+		}
+	}
+	if config.SidecarCaptions && len(captions) > 0 {
+		err = modules.WriteSidecarCaptions(captions, config)
+		if err != nil {
+			return err
+		}
+		log.Println("Wrote sidecar captions")
+	}
+	if config.AudioFormat != "" {
+		err = exportAudio(config, duration, audioSource, posterFile)
 		if err != nil {
 			return err
 		}
 	}
+	return writeDocuments(config, duration, format)
+}
+
+func exportAudio(config config.Data, duration int, webcamVideo modules.Video, posterFile string) error {
+	start := time.Now()
+	tags, err := modules.GetMetadata(config)
+	if err != nil {
+		log.Println("Could not read the recording metadata, exporting audio without tags")
+	}
+	timeline := presentation.GetSlideTimeline(config.RecordingDir, duration)
+	coverImage := posterFile
+	if coverImage == "" {
+		coverImage = presentation.FirstSlideImage(timeline)
+	}
+	err = modules.ExportAudio(webcamVideo, tags, presentation.SlideChapters(timeline), coverImage, config)
+	if err != nil {
+		return err
+	}
+	end := time.Now().Sub(start)
+	log.Println("Audio export took: " + fmt.Sprint(end))
 	return nil
 }
 
+// mixDeskshareAudio adds the screen share audio to the webcam audio, without it the webcam audio is used as is.
+func mixDeskshareAudio(webcamVideo modules.Video, config config.Data) modules.Video {
+	mixed, err := presentation.MixDeskshareAudio(webcamVideo, config)
+	if err != nil {
+		log.Println("Could not mix the screen share audio: " + err.Error())
+		return webcamVideo
+	}
+	return mixed
+}
+
+// createPoster creates the configured poster and writes it as <output>.jpg next to the output.
+func createPoster(config config.Data, duration int, video modules.Video) (string, error) {
+	if config.Poster == "" {
+		return "", nil
+	}
+	posterFile, err := presentation.CreatePoster(config, duration, video)
+	if err != nil {
+		return "", err
+	}
+	err = copyFile(posterFile, strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile))+".jpg")
+	if err != nil {
+		return "", err
+	}
+	log.Println("Created poster image (" + config.Poster + ")")
+	return posterFile, nil
+}
+
 func copyFile(fromFile string, toFile string) error {
 	srcFile, err := os.Open(fromFile)
 	if err != nil {
@@ -102,3 +296,75 @@ func copyFile(fromFile string, toFile string) error {
 	}
 	return destFile.Sync()
 }
+
+func createChatCaption(config config.Data, duration int) (modules.Caption, error) {
+	messages, err := modules.GetChatMessages(config, duration)
+	if err != nil {
+		return modules.Caption{}, err
+	}
+	if len(messages) == 0 {
+		return modules.Caption{}, errors.New("the recording has no public chat messages")
+	}
+	return modules.CreateChatCaption(messages, config)
+}
+
+func addChatPanel(fullVideo modules.Video, config config.Data, duration int) (modules.Video, error) {
+	messages, err := modules.GetChatMessages(config, duration)
+	if err != nil {
+		return modules.Video{}, err
+	}
+	// The panel height follows the rendered layout, the browser viewport uses the same size.
+	config.Height = int64(fullVideo.Height)
+	chatVideo := presentation.RenderChatPanel(config, duration, messages)
+	if chatVideo.VideoPath == "" {
+		log.Println("Skipping the chat panel, no public chat messages were rendered")
+		return fullVideo, nil
+	}
+	start := time.Now()
+	fullVideo, err = modules.AddChatPanel(fullVideo, chatVideo, config)
+	if err != nil {
+		return modules.Video{}, err
+	}
+	end := time.Now().Sub(start)
+	log.Println("Adding the chat panel took: " + fmt.Sprint(end))
+	return fullVideo, nil
+}
+
+// writeDocuments writes the configured exports which are independent of the video next to the output.
+func writeDocuments(config config.Data, duration int, format presentation.RecordingFormat) error {
+	if !format.HasSlides() && (config.SlidesPDF || config.SearchIndex || config.SlideImages != "") {
+		log.Println("Skipping the slide exports, the " + format.Playback + " format has no slides")
+		config.SlidesPDF = false
+		config.SearchIndex = false
+	}
+	if config.SlidesPDF {
+		err := presentation.ExportSlidePDF(config, duration, format)
+		if err != nil {
+			return err
+		}
+	}
+	if config.SearchIndex {
+		err := presentation.WriteSearchIndex(config, duration)
+		if err != nil {
+			return err
+		}
+	}
+	return writeChatTranscript(config, duration)
+}
+
+func writeChatTranscript(config config.Data, duration int) error {
+	if len(config.ChatTranscript) == 0 {
+		return nil
+	}
+	messages, err := modules.GetChatMessages(config, duration)
+	if err != nil {
+		return err
+	}
+	tags, _ := modules.GetMetadata(config)
+	err = modules.WriteChatTranscript(messages, tags["title"], config)
+	if err != nil {
+		return err
+	}
+	log.Println("Wrote chat transcript with " + fmt.Sprint(len(messages)) + " messages")
+	return nil
+}
@@ -0,0 +1,59 @@
+package modules
+
+import (
+	"errors"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"log"
+	"path"
+	"strings"
+)
+
+// ExportAudio writes the webcam audio track as mp3, m4a or opus including tags, chapters and (if supported) cover art.
+func ExportAudio(webcam Video, tags map[string]string, chapters []Chapter, coverImage string, config config.Data) error {
+	if webcam.VideoPath == "" {
+		return errors.New("no webcam audio found to export")
+	}
+	metadataPath := path.Join(config.WorkingDir, "audio.ffmetadata")
+	err := WriteFFMetadata(metadataPath, tags, chapters)
+	if err != nil {
+		return err
+	}
+	outputFile := AudioOutputFile(config)
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", webcam.VideoPath, "-i", metadataPath}
+	withCover := coverImage != "" && config.AudioFormat != "opus"
+	if coverImage != "" && !withCover {
+		log.Println("Cover art is not supported for opus audio, skipping it")
+	}
+	if withCover {
+		cmd = append(cmd, "-i", coverImage)
+	}
+	cmd = append(cmd, "-map", "0:a:0", "-map_metadata", "1", "-map_chapters", "1")
+	if withCover {
+		cmd = append(cmd, "-map", "2:v", "-c:v", "mjpeg", "-disposition:v", "attached_pic", "-metadata:s:v", "title=Cover", "-metadata:s:v", "comment=Cover (front)")
+	}
+	switch config.AudioFormat {
+	case "mp3":
+		cmd = append(cmd, "-c:a", "libmp3lame", "-b:a", "128k", "-id3v2_version", "3")
+	case "m4a":
+		cmd = append(cmd, "-c:a", "aac", "-b:a", "128k", "-movflags", "+faststart")
+	case "opus":
+		cmd = append(cmd, "-c:a", "libopus", "-b:a", "64k")
+	default:
+		return errors.New("unsupported audio format (" + config.AudioFormat + ")")
+	}
+	cmd = append(cmd, "-y", outputFile)
+	_, err = util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AudioOutputFile returns the output file for the audio export, next to the video with the extension of the audio format.
+func AudioOutputFile(config config.Data) string {
+	if config.AudioOnly {
+		return config.OutputFile
+	}
+	return strings.TrimSuffix(config.OutputFile, path.Ext(config.OutputFile)) + "." + config.AudioFormat
+}
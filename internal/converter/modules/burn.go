@@ -0,0 +1,82 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"math"
+	"path"
+	"strings"
+)
+
+// ffmpeg converts srt captions into an ass script with this resolution, all style values are relative to it.
+const (
+	assPlayResX = 384.0
+	assPlayResY = 288.0
+)
+
+// BurnCaption renders the captions of the configured locale into the video. If the webcam column of the layout reaches
+// the caption area (always for top captions), the captions are kept on the presentation side.
+func BurnCaption(fullVideo Video, captions []Caption, presentation Video, webcam Video, config config.Data) (Video, error) {
+	var caption *Caption
+	for i := range captions {
+		if caption == nil && CaptionMatches(captions[i], config.Burn.Locale) {
+			caption = &captions[i]
+		}
+	}
+	if caption == nil {
+		return Video{}, errors.New("no captions found for the locale " + config.Burn.Locale + " to burn into the video")
+	}
+	// The webcam column starts at the top of the video, only bottom captions can be below a short column.
+	webcamWidth := 0.0
+	if presentation.VideoPath != "" && webcam.VideoPath != "" && !webcam.IsOnlyAudio &&
+		(config.Burn.Position == "top" || webcam.Height > fullVideo.Height*0.8) {
+		webcamWidth = webcam.Width
+	}
+	videoPath := path.Join(config.WorkingDir, "out.burned.mp4")
+	filter := "subtitles=filename=" + caption.File + ":force_style='" + burnStyle(config.Burn, fullVideo, webcamWidth) + "'"
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", fullVideo.VideoPath, "-map", "0:v:0", "-map", "0:a?", "-vf", filter}
+	cmd = append(cmd, VideoEncodeArgs(config.Encoding, config.Encoding.Tune)...)
+	cmd = append(cmd, "-c:a", "copy", "-y", videoPath)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return Video{}, err
+	}
+	return GetVideoInfo(videoPath)
+}
+
+func burnStyle(burn config.BurnStyle, video Video, webcamWidth float64) string {
+	scaleY := 1.0
+	scaleX := 1.0
+	if video.Height > 0 && video.Width > 0 {
+		scaleY = assPlayResY / video.Height
+		scaleX = assPlayResX / video.Width
+	}
+	size := float64(burn.Size) * scaleY
+	if burn.Size <= 0 {
+		size = assPlayResY * 0.05
+	}
+	alignment := 2
+	if burn.Position == "top" {
+		alignment = 8
+	}
+	// The filter graph and the style list use , : and ' as separators, they can not be part of the font name.
+	font := strings.NewReplacer(",", "", ":", "", "'", "").Replace(burn.Font)
+	margin := math.Round(size / 2)
+	style := []string{
+		"FontName=" + font,
+		"FontSize=" + fmt.Sprint(math.Round(size)),
+		"Outline=" + fmt.Sprint(math.Round(float64(burn.Outline)*scaleY*10)/10),
+		"Alignment=" + fmt.Sprint(alignment),
+		"MarginV=" + fmt.Sprint(margin),
+		"MarginL=" + fmt.Sprint(margin),
+		"MarginR=" + fmt.Sprint(margin+math.Round(webcamWidth*scaleX)),
+	}
+	if burn.Box {
+		style = append(style, "BorderStyle=3", "OutlineColour=&H80000000", "BackColour=&H80000000")
+	} else {
+		style = append(style, "BorderStyle=1")
+	}
+	return strings.Join(style, ",")
+}
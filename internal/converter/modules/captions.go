@@ -7,8 +7,12 @@ import (
 	"github.com/cli-ish/bbb-video-converter/internal/converter/modules/langs"
 	"github.com/cli-ish/bbb-video-converter/internal/util"
 	"io"
+	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type caption struct {
@@ -16,8 +20,14 @@ type caption struct {
 }
 
 type Caption struct {
-	Code string
-	File string
+	Code    string
+	Locale  string
+	Tag     langs.Tag
+	Title   string
+	File    string
+	Source  string
+	Default bool
+	Forced  bool
 }
 
 func CreateCaptions(config config.Data) ([]Caption, error) {
@@ -48,46 +58,204 @@ func CreateCaptions(config config.Data) ([]Caption, error) {
 	return returnCaptions, nil
 }
 
-func AddCaption(captions []Caption, config config.Data, fullVideo Video) error {
-	tmpFile := path.Join(config.WorkingDir, "video.caption.tmp.mp4")
-	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", fullVideo.VideoPath}
+// AddCaption muxes the captions into the finished output file, the subtitle codec matches the container of the output.
+func AddCaption(captions []Caption, config config.Data, outputFile string) error {
+	// The temporary file is placed next to the output so it can be renamed, the working dir may be another file system.
+	tmpFile := filepath.Join(filepath.Dir(outputFile), ".caption."+filepath.Base(outputFile))
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", outputFile}
 	for _, v := range captions {
-		cmd = append(cmd, "-i", v.File)
+		cmd = append(cmd, "-i", v.Source)
 	}
 	cmd = append(cmd, "-map", "0")
 	for i := range captions {
 		cmd = append(cmd, "-map", fmt.Sprint(i+1)+":s")
 	}
-	cmd = append(cmd, "-c", "copy")
-	for range captions {
-		cmd = append(cmd, "-c:s", "mov_text")
-	}
-	for i, v := range captions {
-		cmd = append(cmd, "-metadata:s:s:"+fmt.Sprint(i), "language="+v.Code)
-	}
+	cmd = append(cmd, "-c", "copy", "-c:s", SubtitleCodec(config, outputFile))
+	cmd = append(cmd, CaptionMetadataArgs(captions)...)
+	cmd = append(cmd, MovFlagsArgs(config, outputFile)...)
 	cmd = append(cmd, "-y", tmpFile)
 	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
 	if err != nil {
 		return err
 	}
-	err = os.Remove(fullVideo.VideoPath)
-	if err != nil {
-		return err
-	}
-	err = os.Rename(tmpFile, fullVideo.VideoPath)
+	err = os.Rename(tmpFile, outputFile)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// SubtitleCodec returns the subtitle codec supported by the container: WebVTT for webm, mov_text for mp4 and the
+// configured codec (webvtt, srt or ass) for mkv.
+func SubtitleCodec(config config.Data, outputFile string) string {
+	switch filepath.Ext(outputFile) {
+	case ".webm":
+		return "webvtt"
+	case ".mkv":
+		return config.MKVSubtitleCodec
+	}
+	return "mov_text"
+}
+
+// CaptionMetadataArgs sets the language, title and default/forced disposition of the caption streams.
+func CaptionMetadataArgs(captions []Caption) []string {
+	var args []string
+	for i, caption := range captions {
+		name, _ := CaptionLanguage(caption)
+		args = append(args, "-metadata:s:s:"+fmt.Sprint(i), "language="+caption.Code, "-metadata:s:s:"+fmt.Sprint(i), "title="+name)
+		var disposition []string
+		if caption.Default {
+			disposition = append(disposition, "default")
+		}
+		if caption.Forced {
+			disposition = append(disposition, "forced")
+		}
+		if len(disposition) == 0 {
+			disposition = append(disposition, "0")
+		}
+		args = append(args, "-disposition:s:"+fmt.Sprint(i), strings.Join(disposition, "+"))
+	}
+	return args
+}
+
 func transformCaptions(config config.Data, Locale string) (Caption, error) {
-	captionCode := langs.LanguageList[Locale].Two
+	tag, parseErr := langs.Parse(Locale)
+	captionCode := tag.Language.Two
+	if parseErr != nil {
+		log.Println("Warning: caption locale " + Locale + " is not a known language, the track is marked as undetermined (" + parseErr.Error() + ")")
+		captionCode = "und"
+	}
 	captionInFile := path.Join(config.RecordingDir, "caption_"+Locale+".vtt")
-	captionOutFile := path.Join(config.WorkingDir, "caption_"+captionCode+".srt")
-	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-threads", config.ThreadCount, "-loglevel", "-warning", "-i", captionInFile, captionOutFile).Output()
+	var err error
+	captionOutFile := path.Join(config.WorkingDir, "caption_"+captionFileName(Locale)+".srt")
+	if config.CaptionOffset != 0 || config.CaptionCleanup || config.CaptionMaxLine > 0 || config.CaptionMaxDuration > 0 {
+		cleanedFile := path.Join(config.WorkingDir, "caption_"+captionFileName(Locale)+".vtt")
+		err = CleanupCaption(captionInFile, cleanedFile, config)
+		if err != nil {
+			return Caption{}, err
+		}
+		captionInFile = cleanedFile
+	}
+	_, err = util.ExecuteCommand("ffmpeg", "-hide_banner", "-threads", config.ThreadCount, "-loglevel", "-warning", "-i", captionInFile, captionOutFile).Output()
 	if err != nil {
 		return Caption{}, err
 	}
-	return Caption{captionCode, captionOutFile}, nil
+	title := Locale
+	if parseErr == nil {
+		title = tag.Title(config.CaptionTitle == "english")
+	}
+	return Caption{Code: captionCode, Locale: Locale, Tag: tag, Title: title, File: captionOutFile, Source: captionInFile}, nil
+}
+
+// captionFileName keeps only letters, digits and dashes of the locale so it can be used in file names.
+func captionFileName(locale string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		if r == '_' {
+			return '-'
+		}
+		return -1
+	}, locale)
+}
+
+// CaptionLanguage returns the track title and the normalized BCP-47 tag of the caption as used in the hls and dash
+// manifests. Unknown locales are marked as undetermined (und).
+func CaptionLanguage(caption Caption) (string, string) {
+	title := caption.Title
+	if title == "" {
+		title = caption.Locale
+	}
+	if caption.Tag.Language.One == "" {
+		return title, "und"
+	}
+	return title, caption.Tag.String()
+}
+
+// captionFileTag returns the language part of the caption file names, unknown locales keep the sanitized locale so
+// the files of several undetermined captions do not overwrite each other.
+func captionFileTag(caption Caption) string {
+	if caption.Tag.Language.One == "" {
+		return captionFileName(caption.Locale)
+	}
+	return caption.Tag.String()
+}
+
+// ArrangeCaptions orders the captions by -caption-order and flags the default and forced track. The default locale
+// "meeting" uses the language meta parameter of the meeting (meta_language on create).
+func ArrangeCaptions(captions []Caption, config config.Data) []Caption {
+	order := strings.Split(config.CaptionOrder, ",")
+	rank := func(caption Caption) int {
+		for i, locale := range order {
+			if CaptionMatches(caption, strings.TrimSpace(locale)) {
+				return i
+			}
+		}
+		return len(order)
+	}
+	sort.SliceStable(captions, func(i, j int) bool {
+		return rank(captions[i]) < rank(captions[j])
+	})
+	defaultLocale := config.DefaultCaption
+	if defaultLocale == "meeting" {
+		defaultLocale, _ = GetMetaEntry(config, "language")
+		if defaultLocale == "" {
+			log.Println("The meeting has no language meta parameter, no caption is marked as default")
+		}
+	}
+	defaultSet := false
+	forcedSet := false
+	for i := range captions {
+		if !defaultSet && defaultLocale != "" && CaptionMatches(captions[i], defaultLocale) {
+			captions[i].Default = true
+			defaultSet = true
+		}
+		if !forcedSet && config.ForcedCaption != "" && CaptionMatches(captions[i], config.ForcedCaption) {
+			captions[i].Forced = true
+			forcedSet = true
+		}
+	}
+	return captions
+}
+
+// CaptionMatches reports whether the caption belongs to the locale, a plain language (de) also matches regional tags (de-AT).
+func CaptionMatches(caption Caption, locale string) bool {
+	if locale == "" {
+		return false
+	}
+	if caption.Locale == locale || caption.Code == locale {
+		return true
+	}
+	tag, err := langs.Parse(locale)
+	if err != nil || caption.Tag.Language.One != tag.Language.One {
+		return false
+	}
+	return (tag.Region == "" || tag.Region == caption.Tag.Region) && (tag.Script == "" || tag.Script == caption.Tag.Script)
+}
+
+// WriteSidecarCaptions writes every caption as normalized <output>.<lang>.vtt and <output>.<lang>.srt next to the output.
+func WriteSidecarCaptions(captions []Caption, config config.Data) error {
+	base := strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile))
+	for _, caption := range captions {
+		code := captionFileTag(caption)
+		err := convertCaption(caption, base+"."+code+".vtt", "webvtt")
+		if err != nil {
+			return err
+		}
+		err = convertCaption(caption, base+"."+code+".srt", "srt")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertCaption converts the WebVTT source of the caption into the target file with the given format (webvtt or srt).
+func convertCaption(caption Caption, target string, format string) error {
+	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-i", caption.Source, "-c:s", format, "-f", format, "-y", target).Output()
+	if err != nil {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,39 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type Chapter struct {
+	Start float64
+	End   float64
+	Title string
+}
+
+// WriteFFMetadata writes the tags and chapters into the ffmpeg metadata format so they can be mapped with -map_metadata.
+func WriteFFMetadata(metadataPath string, tags map[string]string, chapters []Chapter) error {
+	content := ";FFMETADATA1\n"
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		content += k + "=" + escapeFFMetadata(tags[k]) + "\n"
+	}
+	for _, chapter := range chapters {
+		content += "[CHAPTER]\nTIMEBASE=1/1000\n"
+		content += "START=" + fmt.Sprint(int64(chapter.Start*1000)) + "\n"
+		content += "END=" + fmt.Sprint(int64(chapter.End*1000)) + "\n"
+		content += "title=" + escapeFFMetadata(chapter.Title) + "\n"
+	}
+	return os.WriteFile(metadataPath, []byte(content), 0o644)
+}
+
+func escapeFFMetadata(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#", "\n", "\\\n")
+	return replacer.Replace(value)
+}
@@ -0,0 +1,140 @@
+package modules
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type popcorn struct {
+	XMLName xml.Name       `xml:"popcorn"`
+	Chat    []chatTimeline `xml:"chattimeline"`
+}
+
+type chatTimeline struct {
+	In       float64 `xml:"in,attr"`
+	Name     string  `xml:"name,attr"`
+	Message  string  `xml:"message,attr"`
+	SenderId string  `xml:"senderId,attr"`
+	Role     string  `xml:"senderRole,attr"`
+	Target   string  `xml:"target,attr"`
+}
+
+type ChatMessage struct {
+	Time      float64
+	Name      string
+	Message   string
+	Moderator bool
+}
+
+// chatDisplayTime is how long a chat message stays visible in the chat subtitle track.
+const chatDisplayTime = 8.0
+
+// chatVisibleMessages is the maximum number of messages shown at once in the chat subtitle track.
+const chatVisibleMessages = 3
+
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// vttEscaper escapes the characters which would start a cue tag or an entity in the WebVTT cue text.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// GetChatMessages parses the public chat from slides_new.xml, names are anonymized and roles filtered as configured.
+func GetChatMessages(config config.Data, duration int) ([]ChatMessage, error) {
+	chatFile, err := os.Open(path.Join(config.RecordingDir, "slides_new.xml"))
+	if err != nil {
+		return []ChatMessage{}, nil
+	}
+	defer chatFile.Close()
+	byteValue, _ := io.ReadAll(chatFile)
+	var pop popcorn
+	err = xml.Unmarshal(byteValue, &pop)
+	if err != nil {
+		return []ChatMessage{}, err
+	}
+	anonymized := map[string]string{}
+	var messages []ChatMessage
+	for _, chat := range pop.Chat {
+		if chat.In >= float64(duration) || (chat.Target != "" && chat.Target != "chat") {
+			continue
+		}
+		moderator := strings.EqualFold(chat.Role, "MODERATOR")
+		if (config.ChatRoles == "moderator" && !moderator) || (config.ChatRoles == "viewer" && moderator) {
+			continue
+		}
+		message := html.UnescapeString(htmlTagRegex.ReplaceAllString(chat.Message, ""))
+		// Line breaks and arrows would break the cue structure of the subtitle track.
+		message = strings.ReplaceAll(strings.Join(strings.Fields(message), " "), "-->", "->")
+		if message == "" {
+			continue
+		}
+		name := chat.Name
+		if config.ChatAnonymize {
+			key := chat.SenderId
+			if key == "" {
+				key = chat.Name
+			}
+			if _, ok := anonymized[key]; !ok {
+				anonymized[key] = "Participant " + fmt.Sprint(len(anonymized)+1)
+			}
+			name = anonymized[key]
+		}
+		messages = append(messages, ChatMessage{Time: chat.In, Name: name, Message: message, Moderator: moderator})
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Time < messages[j].Time
+	})
+	return messages, nil
+}
+
+// CreateChatCaption writes the chat as subtitle track, each message is shown as "Name: message" when it was sent
+// together with the still visible previous messages.
+func CreateChatCaption(messages []ChatMessage, config config.Data) (Caption, error) {
+	vtt := vttFile{}
+	for i, message := range messages {
+		end := message.Time + chatDisplayTime
+		if i+1 < len(messages) {
+			end = math.Min(end, messages[i+1].Time)
+		}
+		if end <= message.Time {
+			continue
+		}
+		var lines []string
+		for j := i; j >= 0 && j > i-chatVisibleMessages; j-- {
+			if message.Time-messages[j].Time >= chatDisplayTime {
+				break
+			}
+			lines = append([]string{vttEscaper.Replace(messages[j].Name + ": " + messages[j].Message)}, lines...)
+		}
+		vtt.Cues = append(vtt.Cues, cue{Start: message.Time, End: end, Text: strings.Join(lines, "\n")})
+	}
+	source := path.Join(config.WorkingDir, "chat.vtt")
+	err := os.WriteFile(source, []byte(vtt.String()), 0o644)
+	if err != nil {
+		return Caption{}, err
+	}
+	srtFile := path.Join(config.WorkingDir, "chat.srt")
+	_, err = util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-i", source, "-y", srtFile).Output()
+	if err != nil {
+		return Caption{}, err
+	}
+	// The chat has no language tag, the locale only names the sidecar files, the streams and manifests get und.
+	return Caption{Code: "und", Locale: "chat", Title: "Chat", File: srtFile, Source: source}, nil
+}
+
+// FormatChatTime formats the chat time like the BBB playback, mm:ss or h:mm:ss for longer recordings.
+func FormatChatTime(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
@@ -0,0 +1,22 @@
+package modules
+
+import (
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"os"
+	"path/filepath"
+)
+
+// AddCoverArt embeds the poster as cover art into the mp4 output, webm has no support for it.
+func AddCoverArt(posterFile string, config config.Data, outputFile string) error {
+	tmpFile := filepath.Join(filepath.Dir(outputFile), ".cover."+filepath.Base(outputFile))
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", outputFile, "-i", posterFile}
+	cmd = append(cmd, "-map", "0", "-map", "1", "-c", "copy", "-c:v:1", "mjpeg", "-disposition:v:1", "attached_pic")
+	cmd = append(cmd, MovFlagsArgs(config, outputFile)...)
+	cmd = append(cmd, "-y", tmpFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, outputFile)
+}
@@ -0,0 +1,110 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteDASH encodes all renditions in one run into a dash manifest with CMAF segments, the captions are added as WebVTT text tracks.
+// The bitrates are the video bitrates of the renditions in the same order, 0 encodes with constant quality.
+func WriteDASH(input Video, captions []Caption, config config.Data, bitrates []int64) error {
+	base := filepath.Base(strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile)))
+	filter := "[0:v]split=" + fmt.Sprint(len(config.Renditions))
+	for i := range config.Renditions {
+		filter += "[s" + fmt.Sprint(i) + "]"
+	}
+	for i, rendition := range config.Renditions {
+		filter += ";[s" + fmt.Sprint(i) + "]scale=-2:" + fmt.Sprint(rendition.Height) + "[v" + fmt.Sprint(i) + "]"
+	}
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath, "-filter_complex", filter}
+	for i := range config.Renditions {
+		cmd = append(cmd, "-map", "[v"+fmt.Sprint(i)+"]")
+	}
+	withAudio := HasAudio(input.VideoPath)
+	if withAudio {
+		cmd = append(cmd, "-map", "0:a:0")
+	}
+	// One adaptation set per codec, players can only switch between representations of the same codec.
+	var codecs []string
+	codecStreams := map[string][]string{}
+	for i, rendition := range config.Renditions {
+		rendition.Encoding.Bitrate = bitrates[i]
+		cmd = append(cmd, videoEncodeArgs(rendition.Encoding, rendition.Encoding.Tune, "v:"+fmt.Sprint(i))...)
+		if _, ok := codecStreams[rendition.Encoding.Codec]; !ok {
+			codecs = append(codecs, rendition.Encoding.Codec)
+		}
+		codecStreams[rendition.Encoding.Codec] = append(codecStreams[rendition.Encoding.Codec], fmt.Sprint(i))
+	}
+	var adaptationSets []string
+	for i, codec := range codecs {
+		adaptationSets = append(adaptationSets, "id="+fmt.Sprint(i)+",streams="+strings.Join(codecStreams[codec], ","))
+	}
+	if withAudio {
+		adaptationSets = append(adaptationSets, "id="+fmt.Sprint(len(codecs))+",streams=a")
+	}
+	cmd = append(cmd, SegmentKeyframeArgs(config.Renditions[0].Encoding, config.SegmentTime)...)
+	cmd = append(cmd, AudioEncodeArgs(config.OutputFile)...)
+	cmd = append(cmd, "-f", "dash", "-seg_duration", fmt.Sprint(config.SegmentTime), "-dash_segment_type", "mp4", "-use_template", "1", "-use_timeline", "1",
+		"-adaptation_sets", strings.Join(adaptationSets, " "),
+		"-init_seg_name", base+"_init_$RepresentationID$.$ext$", "-media_seg_name", base+"_chunk_$RepresentationID$_$Number%05d$.$ext$",
+		"-y", config.OutputFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	if len(captions) > 0 {
+		return addDASHTextTracks(captions, config, len(adaptationSets))
+	}
+	return nil
+}
+
+// addDASHTextTracks writes the captions as WebVTT sidecar files and references them as text adaptation sets in the manifest.
+func addDASHTextTracks(captions []Caption, config config.Data, firstId int) error {
+	content, err := os.ReadFile(config.OutputFile)
+	if err != nil {
+		return err
+	}
+	manifest := string(content)
+	periodEnd := strings.LastIndex(manifest, "</Period>")
+	if periodEnd == -1 {
+		return errors.New("dash manifest does not contain a period")
+	}
+	textSets := ""
+	for i, caption := range captions {
+		vttFile, err := writeSubtitleVTT(caption, config.OutputFile, "")
+		if err != nil {
+			return err
+		}
+		name, code := CaptionLanguage(caption)
+		textSets += "\t\t<AdaptationSet id=\"" + fmt.Sprint(firstId+i) + "\" contentType=\"text\" mimeType=\"text/vtt\" lang=\"" + code + "\">\n" +
+			"\t\t\t<Label>" + escapeXML(name) + "</Label>\n" +
+			"\t\t\t<Role schemeIdUri=\"urn:mpeg:dash:role:2011\" value=\"" + dashRole(caption) + "\"/>\n" +
+			"\t\t\t<Representation id=\"sub_" + code + "\" bandwidth=\"256\">\n" +
+			"\t\t\t\t<BaseURL>" + escapeXML(filepath.Base(vttFile)) + "</BaseURL>\n" +
+			"\t\t\t</Representation>\n" +
+			"\t\t</AdaptationSet>\n"
+	}
+	lineStart := strings.LastIndex(manifest[:periodEnd], "\n") + 1
+	manifest = manifest[:lineStart] + textSets + manifest[lineStart:]
+	return os.WriteFile(config.OutputFile, []byte(manifest), 0o644)
+}
+
+func dashRole(caption Caption) string {
+	if caption.Forced {
+		return "forced-subtitle"
+	}
+	if caption.Default {
+		return "main"
+	}
+	return "subtitle"
+}
+
+func escapeXML(value string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "'", "&apos;")
+	return replacer.Replace(value)
+}
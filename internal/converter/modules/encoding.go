@@ -0,0 +1,106 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"strings"
+)
+
+var videoEncoders = map[string]string{
+	"h264": "libx264",
+	"h265": "libx265",
+	"vp9":  "libvpx-vp9",
+	"av1":  "libsvtav1",
+}
+
+// probeCodecs are the ffprobe codec names of the encoders.
+var probeCodecs = map[string]string{
+	"h264": "h264",
+	"h265": "hevc",
+	"vp9":  "vp9",
+	"av1":  "av1",
+}
+
+// VideoEncodeArgs returns the ffmpeg video encoder arguments of the encoding profile, tune overrides the profile tune.
+func VideoEncodeArgs(encoding config.Encoding, tune string) []string {
+	return videoEncodeArgs(encoding, tune, "v")
+}
+
+// videoEncodeArgs builds the encoder arguments for the given stream specifier (e.g. "v" or "v:1").
+func videoEncodeArgs(encoding config.Encoding, tune string, stream string) []string {
+	args := []string{"-c:" + stream, videoEncoders[encoding.Codec]}
+	// With a bitrate set the encoder runs in (two-pass) vbr mode instead of constant quality.
+	rateControl := []string{"-crf:" + stream, fmt.Sprint(encoding.CRF)}
+	if encoding.Bitrate > 0 {
+		rateControl = []string{"-b:" + stream, fmt.Sprint(encoding.Bitrate)}
+	}
+	switch encoding.Codec {
+	case "h264", "h265":
+		args = append(args, "-preset:"+stream, encoding.Preset)
+		args = append(args, rateControl...)
+		// x265 does not know the stillimage tune, it is only a hint so we skip it.
+		if tune != "" && (encoding.Codec == "h264" || tune != "stillimage") {
+			args = append(args, "-tune:"+stream, tune)
+		}
+	case "vp9":
+		args = append(args, "-deadline:"+stream, "good", "-cpu-used:"+stream, encoding.Preset, "-row-mt:"+stream, "1")
+		args = append(args, rateControl...)
+		if encoding.Bitrate == 0 {
+			args = append(args, "-b:"+stream, "0")
+		}
+		if tune == "stillimage" {
+			args = append(args, "-tune-content:"+stream, "screen")
+		}
+	case "av1":
+		args = append(args, "-preset:"+stream, encoding.Preset)
+		args = append(args, rateControl...)
+	}
+	if encoding.GOP > 0 {
+		args = append(args, "-g:"+stream, fmt.Sprint(encoding.GOP))
+	}
+	if encoding.PixelFormat != "" {
+		args = append(args, "-pix_fmt:"+stream, encoding.PixelFormat)
+	}
+	return args
+}
+
+// AudioEncodeArgs returns the audio encoder matching the container of the output file.
+func AudioEncodeArgs(outputFile string) []string {
+	if isWebm(outputFile) {
+		return []string{"-c:a", "libopus", "-b:a", fmt.Sprint(audioBitrate(outputFile))}
+	}
+	return []string{"-c:a", "aac", "-b:a", fmt.Sprint(audioBitrate(outputFile))}
+}
+
+func audioBitrate(outputFile string) int64 {
+	if isWebm(outputFile) {
+		return 96000
+	}
+	return 128000
+}
+
+// ScaleArgs returns the filter to scale the video to the rendition height, the width keeps the aspect ratio.
+func ScaleArgs(rendition config.Rendition) []string {
+	if rendition.Height == 0 {
+		return []string{}
+	}
+	return []string{"-vf", "scale=-2:" + fmt.Sprint(rendition.Height)}
+}
+
+// MovFlagsArgs returns the -movflags for faststart or fragmented mp4 outputs, other containers get none.
+func MovFlagsArgs(config config.Data, outputFile string) []string {
+	if !strings.HasSuffix(outputFile, ".mp4") {
+		return []string{}
+	}
+	if config.FastStart {
+		return []string{"-movflags", "+faststart"}
+	}
+	if config.Fragmented {
+		return []string{"-movflags", "+frag_keyframe+empty_moov+default_base_moof"}
+	}
+	return []string{}
+}
+
+func isWebm(file string) bool {
+	return strings.HasSuffix(file, ".webm")
+}
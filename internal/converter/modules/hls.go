@@ -0,0 +1,343 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type hlsVariant struct {
+	Playlist         string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Width            int64
+	Height           int64
+	Codecs           string
+}
+
+// The RFC 6381 codec strings of the variants are built from the profile and level ffprobe reports, players skip
+// variants whose announced codec, profile or level they can not decode.
+var avcProfiles = map[string]string{
+	"Constrained Baseline":  "42C0",
+	"Baseline":              "4200",
+	"Main":                  "4D40",
+	"High":                  "6400",
+	"High 10":               "6E00",
+	"High 4:2:2":            "7A00",
+	"High 4:4:4 Predictive": "F400",
+}
+
+var hevcProfiles = map[string]string{
+	"Main":    "1.6",
+	"Main 10": "2.4",
+	"Rext":    "4.16",
+}
+
+var av1Profiles = map[string]string{
+	"Main":         "0",
+	"High":         "1",
+	"Professional": "2",
+}
+
+var vp9Profiles = map[string]string{
+	"Profile 0": "00",
+	"Profile 1": "01",
+	"Profile 2": "02",
+	"Profile 3": "03",
+}
+
+// vp9Levels are the maximum luma picture sizes of the vp9 levels, libvpx does not write the level into the stream.
+var vp9Levels = []struct {
+	Level   int
+	MaxSize int
+}{
+	{10, 36864}, {11, 73728}, {20, 122880}, {21, 245760}, {30, 552960}, {31, 983040}, {40, 2228224}, {50, 8912896}, {60, 35651584},
+}
+
+type probedVideoStream struct {
+	Codec       string `json:"codec_name"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Profile     string `json:"profile"`
+	Level       int    `json:"level"`
+	PixelFormat string `json:"pix_fmt"`
+}
+
+// hlsAudioCodec is the codec string of the aac-lc audio of the variants.
+const hlsAudioCodec = "mp4a.40.2"
+
+// WriteHLSVariant encodes one rendition as hls media playlist, the keyframes are aligned to the segments of all renditions.
+func WriteHLSVariant(input Video, config config.Data, rendition config.Rendition, bitrate int64) error {
+	rendition.Encoding.Bitrate = bitrate
+	base := strings.TrimSuffix(rendition.OutputFile, filepath.Ext(rendition.OutputFile))
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath, "-map", "0:v:0", "-map", "0:a?"}
+	cmd = append(cmd, ScaleArgs(rendition)...)
+	cmd = append(cmd, VideoEncodeArgs(rendition.Encoding, rendition.Encoding.Tune)...)
+	if rendition.Encoding.Codec == "h265" {
+		// Apple players only accept hevc in the hvc1 sample entry which is also announced in the master playlist.
+		cmd = append(cmd, "-tag:v", "hvc1")
+	}
+	cmd = append(cmd, SegmentKeyframeArgs(rendition.Encoding, config.SegmentTime)...)
+	cmd = append(cmd, AudioEncodeArgs(rendition.OutputFile)...)
+	cmd = append(cmd, "-f", "hls", "-hls_time", fmt.Sprint(config.SegmentTime), "-hls_playlist_type", "vod", "-hls_flags", "independent_segments")
+	if hlsFmp4(rendition) {
+		cmd = append(cmd, "-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", filepath.Base(base)+"_init.mp4", "-hls_segment_filename", base+"_%05d.m4s")
+	} else {
+		cmd = append(cmd, "-hls_segment_type", "mpegts", "-hls_segment_filename", base+"_%05d.ts")
+	}
+	cmd = append(cmd, "-y", rendition.OutputFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteHLSMaster writes the master playlist referencing all renditions and the captions as WebVTT subtitle renditions.
+func WriteHLSMaster(input Video, captions []Caption, config config.Data, duration int) error {
+	var variants []hlsVariant
+	withAudio := HasAudio(input.VideoPath)
+	for _, rendition := range config.Renditions {
+		variant, err := measureHLSVariant(input, rendition)
+		if err != nil {
+			return err
+		}
+		variant.Codecs, err = hlsVideoCodec(rendition)
+		if err != nil {
+			return err
+		}
+		if withAudio {
+			variant.Codecs += "," + hlsAudioCodec
+		}
+		variants = append(variants, variant)
+	}
+	version := 6
+	for _, rendition := range config.Renditions {
+		if hlsFmp4(rendition) {
+			version = 7
+		}
+	}
+	content := "#EXTM3U\n#EXT-X-VERSION:" + fmt.Sprint(version) + "\n#EXT-X-INDEPENDENT-SEGMENTS\n"
+	subtitles := ""
+	if len(captions) > 0 {
+		subtitles = ",SUBTITLES=\"subs\""
+		timestampMap := ""
+		if !hlsFmp4(config.Renditions[0]) {
+			timestampMap = mpegtsTimestampMap(config.Renditions[0].OutputFile)
+		}
+		for _, caption := range captions {
+			playlist, err := writeHLSSubtitle(caption, config, duration, timestampMap)
+			if err != nil {
+				return err
+			}
+			name, code := CaptionLanguage(caption)
+			flags := "DEFAULT=NO"
+			if caption.Default {
+				flags = "DEFAULT=YES"
+			}
+			if caption.Forced {
+				flags += ",FORCED=YES"
+			}
+			content += "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"" + name + "\",LANGUAGE=\"" + code + "\"," + flags + ",AUTOSELECT=YES,URI=\"" + filepath.Base(playlist) + "\"\n"
+		}
+	}
+	for _, variant := range variants {
+		content += "#EXT-X-STREAM-INF:BANDWIDTH=" + fmt.Sprint(variant.Bandwidth) + ",AVERAGE-BANDWIDTH=" + fmt.Sprint(variant.AverageBandwidth) +
+			",RESOLUTION=" + fmt.Sprint(variant.Width) + "x" + fmt.Sprint(variant.Height) + ",CODECS=\"" + variant.Codecs + "\"" + subtitles + "\n"
+		content += filepath.Base(variant.Playlist) + "\n"
+	}
+	return os.WriteFile(config.OutputFile, []byte(content), 0o644)
+}
+
+// SegmentKeyframeArgs forces a keyframe at every segment boundary so all renditions can switch at the same positions.
+func SegmentKeyframeArgs(encoding config.Encoding, segmentTime int) []string {
+	args := []string{"-force_key_frames", "expr:gte(t,n_forced*" + fmt.Sprint(segmentTime) + ")"}
+	if encoding.Codec == "h264" {
+		args = append(args, "-sc_threshold", "0")
+	}
+	return args
+}
+
+func hlsFmp4(rendition config.Rendition) bool {
+	return rendition.Encoding.Codec != "h264"
+}
+
+// measureHLSVariant calculates the peak and average bandwidth from the segments of the media playlist.
+func measureHLSVariant(input Video, rendition config.Rendition) (hlsVariant, error) {
+	variant := hlsVariant{Playlist: rendition.OutputFile, Width: int64(input.Width), Height: int64(input.Height)}
+	if rendition.Height > 0 && input.Height > 0 {
+		variant.Height = rendition.Height
+		variant.Width = int64(math.Round(float64(rendition.Height)*input.Width/(input.Height*2))) * 2
+	}
+	segments, err := readPlaylistSegments(rendition.OutputFile)
+	if err != nil {
+		return hlsVariant{}, err
+	}
+	var totalBits float64
+	var totalDuration float64
+	for _, segment := range segments {
+		info, err := os.Stat(segment.File)
+		if err != nil {
+			return hlsVariant{}, err
+		}
+		bits := float64(info.Size() * 8)
+		totalBits += bits
+		totalDuration += segment.Duration
+		if segment.Duration > 0 {
+			variant.Bandwidth = max(variant.Bandwidth, int64(bits/segment.Duration))
+		}
+	}
+	if totalDuration > 0 {
+		variant.AverageBandwidth = int64(totalBits / totalDuration)
+	}
+	return variant, nil
+}
+
+// hlsVideoCodec builds the codec string of the variant from its first segment, fmp4 variants keep the codec
+// parameters in the init segment.
+func hlsVideoCodec(rendition config.Rendition) (string, error) {
+	segments, err := readPlaylistSegments(rendition.OutputFile)
+	if err != nil {
+		return "", err
+	}
+	if len(segments) == 0 {
+		return "", errors.New("the hls playlist has no segments (" + rendition.OutputFile + ")")
+	}
+	file := segments[0].File
+	if hlsFmp4(rendition) {
+		file = strings.TrimSuffix(rendition.OutputFile, filepath.Ext(rendition.OutputFile)) + "_init.mp4"
+	}
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=codec_name,width,height,profile,level,pix_fmt", "-of", "json", file).Output()
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		Streams []probedVideoStream `json:"streams"`
+	}
+	err = json.Unmarshal(out, &info)
+	if err != nil {
+		return "", err
+	}
+	if len(info.Streams) == 0 {
+		return "", errors.New("the hls segment has no video stream (" + file + ")")
+	}
+	stream := info.Streams[0]
+	bitDepth := "08"
+	if strings.Contains(stream.PixelFormat, "10") {
+		bitDepth = "10"
+	}
+	switch stream.Codec {
+	case "h264":
+		if profile, ok := avcProfiles[stream.Profile]; ok && stream.Level > 0 {
+			return fmt.Sprintf("avc1.%s%02X", profile, stream.Level), nil
+		}
+	case "hevc":
+		if profile, ok := hevcProfiles[stream.Profile]; ok && stream.Level > 0 {
+			return "hvc1." + profile + ".L" + fmt.Sprint(stream.Level) + ".B0", nil
+		}
+	case "av1":
+		if profile, ok := av1Profiles[stream.Profile]; ok && stream.Level >= 0 {
+			return fmt.Sprintf("av01.%s.%02dM.%s", profile, stream.Level, bitDepth), nil
+		}
+	case "vp9":
+		if stream.Level <= 0 {
+			for _, level := range vp9Levels {
+				if stream.Level <= 0 && stream.Width*stream.Height <= level.MaxSize {
+					stream.Level = level.Level
+				}
+			}
+		}
+		if profile, ok := vp9Profiles[stream.Profile]; ok && stream.Level > 0 {
+			return fmt.Sprintf("vp09.%s.%02d.%s", profile, stream.Level, bitDepth), nil
+		}
+	}
+	return "", errors.New("unknown codec profile " + stream.Codec + " " + stream.Profile + " (level " + fmt.Sprint(stream.Level) + ") in " + filepath.Base(file))
+}
+
+type playlistSegment struct {
+	File     string
+	Duration float64
+}
+
+func readPlaylistSegments(playlist string) ([]playlistSegment, error) {
+	file, err := os.Open(playlist)
+	if err != nil {
+		return []playlistSegment{}, err
+	}
+	defer file.Close()
+	var segments []playlistSegment
+	duration := 0.0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#EXTINF:") {
+			duration, _ = strconv.ParseFloat(strings.Split(line[len("#EXTINF:"):], ",")[0], 64)
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			segments = append(segments, playlistSegment{filepath.Join(filepath.Dir(playlist), line), duration})
+		}
+	}
+	return segments, scanner.Err()
+}
+
+// mpegtsTimestampMap returns the X-TIMESTAMP-MAP header which syncs the WebVTT cues with the start pts of the mpegts segments.
+func mpegtsTimestampMap(playlist string) string {
+	segments, err := readPlaylistSegments(playlist)
+	if err != nil || len(segments) == 0 {
+		return ""
+	}
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=start_time", "-of", "csv=p=0", segments[0].File).Output()
+	if err != nil {
+		return ""
+	}
+	start, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return ""
+	}
+	return "X-TIMESTAMP-MAP=MPEGTS:" + fmt.Sprint(int64(math.Round(start*90000))) + ",LOCAL:00:00:00.000"
+}
+
+// writeHLSSubtitle writes the caption as a single segment WebVTT playlist next to the master playlist.
+func writeHLSSubtitle(caption Caption, config config.Data, duration int, timestampMap string) (string, error) {
+	vttFile, err := writeSubtitleVTT(caption, config.OutputFile, timestampMap)
+	if err != nil {
+		return "", err
+	}
+	playlist := strings.TrimSuffix(vttFile, ".vtt") + ".m3u8"
+	content := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:" + fmt.Sprint(duration) + "\n#EXT-X-PLAYLIST-TYPE:VOD\n" +
+		"#EXTINF:" + fmt.Sprint(duration) + ".000,\n" + filepath.Base(vttFile) + "\n#EXT-X-ENDLIST\n"
+	return playlist, os.WriteFile(playlist, []byte(content), 0o644)
+}
+
+// writeSubtitleVTT writes the caption as normalized WebVTT file (<output>_sub_<code>.vtt) next to the output file.
+func writeSubtitleVTT(caption Caption, outputFile string, header string) (string, error) {
+	tag := captionFileTag(caption)
+	vttFile := strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "_sub_" + tag + ".vtt"
+	err := convertCaption(caption, vttFile, "webvtt")
+	if err != nil {
+		return "", err
+	}
+	if header == "" {
+		return vttFile, nil
+	}
+	content, err := os.ReadFile(vttFile)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.SplitN(string(content), "\n", 2)
+	if len(lines) == 2 {
+		err = os.WriteFile(vttFile, []byte(lines[0]+"\n"+header+"\n"+lines[1]), 0o644)
+		if err != nil {
+			return "", err
+		}
+	}
+	return vttFile, nil
+}
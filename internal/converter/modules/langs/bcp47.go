@@ -0,0 +1,100 @@
+package langs
+
+import (
+	"errors"
+	"strings"
+)
+
+type Tag struct {
+	Language Language
+	Script   string
+	Region   string
+}
+
+// deprecatedCodes maps the old ISO 639-1 codes which are still used by some browsers to the current ones.
+var deprecatedCodes = map[string]string{"iw": "he", "in": "id", "ji": "yi", "jw": "jv", "mo": "ro"}
+
+// Parse resolves a BCP-47 tag (e.g. en-US, pt_BR, zh-Hant-TW) to its language, the script and region are kept.
+// The primary language can be an ISO 639-1 or ISO 639-2/3 code, variants and extensions are ignored.
+func Parse(tag string) (Tag, error) {
+	parts := strings.FieldsFunc(tag, func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	if len(parts) == 0 {
+		return Tag{}, errors.New("empty language tag")
+	}
+	primary := strings.ToLower(parts[0])
+	if replacement, ok := deprecatedCodes[primary]; ok {
+		primary = replacement
+	}
+	language, ok := lookup(primary)
+	if !ok {
+		return Tag{}, errors.New("unknown language (" + tag + ")")
+	}
+	result := Tag{Language: language}
+	for _, part := range parts[1:] {
+		switch {
+		case len(part) == 4 && result.Script == "" && result.Region == "":
+			result.Script = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		case (len(part) == 2 || (len(part) == 3 && isDigits(part))) && result.Region == "":
+			result.Region = strings.ToUpper(part)
+		}
+	}
+	return result, nil
+}
+
+// String returns the normalized tag, e.g. pt-BR or zh-Hant.
+func (t Tag) String() string {
+	parts := []string{t.Language.One}
+	if t.Script != "" {
+		parts = append(parts, t.Script)
+	}
+	if t.Region != "" {
+		parts = append(parts, t.Region)
+	}
+	return strings.Join(parts, "-")
+}
+
+// Title returns the language name in its own language (or in english), the script and region are appended,
+// e.g. "Português (BR)".
+func (t Tag) Title(english bool) string {
+	name := t.Language.Local
+	if english {
+		name = t.Language.Name
+	}
+	var details []string
+	if t.Script != "" {
+		details = append(details, t.Script)
+	}
+	if t.Region != "" {
+		details = append(details, t.Region)
+	}
+	if len(details) == 0 {
+		return name
+	}
+	return name + " (" + strings.Join(details, ", ") + ")"
+}
+
+func lookup(code string) (Language, bool) {
+	if language, ok := LanguageList[code]; ok {
+		return language, true
+	}
+	if len(code) != 3 {
+		return Language{}, false
+	}
+	for _, language := range LanguageList {
+		if language.Two == code || language.TwoT == code || language.TwoB == code || language.Three == code {
+			return language, true
+		}
+	}
+	return Language{}, false
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
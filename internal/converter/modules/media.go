@@ -0,0 +1,63 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// mediaFiles are the files the video and podcast playback formats publish, depending on the BBB version.
+var mediaFiles = map[string][]string{
+	"video":   {"video-0.m4v", "video.mp4", "video.m4v", "video.webm"},
+	"podcast": {"audio.ogg", "audio.webm", "audio.m4a", "audio/audio.ogg", "audio/audio.webm"},
+}
+
+// GetRecordingMedia returns the published media file of a video or podcast recording, podcasts are audio only.
+func GetRecordingMedia(config config.Data, playback string) (Video, error) {
+	for _, name := range mediaFiles[playback] {
+		mediaPath := path.Join(config.RecordingDir, name)
+		_, err := os.Stat(mediaPath)
+		if err != nil {
+			continue
+		}
+		if playback == "podcast" {
+			duration, err := mediaDuration(mediaPath)
+			if err != nil {
+				return Video{}, err
+			}
+			return Video{VideoPath: mediaPath, Duration: duration, IsOnlyAudio: true}, nil
+		}
+		return GetVideoInfo(mediaPath)
+	}
+	return Video{}, errors.New("no media file found for the " + playback + " recording (" + strings.Join(mediaFiles[playback], ", ") + ")")
+}
+
+// CreateStillVideo turns the audio of a podcast into a video showing the image, the image is padded to the output size.
+func CreateStillVideo(image string, audio Video, config config.Data) (Video, error) {
+	videoPath := path.Join(config.WorkingDir, "out.mp4")
+	filter := "scale=w=" + fmt.Sprint(config.Width) + ":h=" + fmt.Sprint(config.Height) + ":force_original_aspect_ratio=decrease," +
+		"pad=" + fmt.Sprint(config.Width) + ":" + fmt.Sprint(config.Height) + ":(ow-iw)/2:(oh-ih)/2:color=white"
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-loop", "1", "-framerate", "5", "-i", image,
+		"-i", audio.VideoPath, "-map", "0:v", "-map", "1:a:0", "-vf", filter}
+	cmd = append(cmd, VideoEncodeArgs(config.Encoding, config.Encoding.SlideTune)...)
+	cmd = append(cmd, "-c:a", "aac", "-shortest", "-y", videoPath)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return Video{}, err
+	}
+	return GetVideoInfo(videoPath)
+}
+
+func mediaDuration(file string) (float64, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", file).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
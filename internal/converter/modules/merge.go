@@ -43,7 +43,10 @@ func CombinePresentationWithWebcams(presentation Video, webcam Video, config con
 }
 
 func copyWebcamsVideo(webcam Video, videoPath string, config config.Data) error {
-	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", webcam.VideoPath, "-y", videoPath).Output()
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", webcam.VideoPath}
+	cmd = append(cmd, VideoEncodeArgs(config.Encoding, config.Encoding.Tune)...)
+	cmd = append(cmd, "-c:a", "aac", "-y", videoPath)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
 	if err != nil {
 		return err
 	}
@@ -64,15 +67,55 @@ func stackWebcamsToPresentation(presentation Video, webcam Video, videoPath stri
 	if int(height)%2 == 1 {
 		height += 1
 	}
-	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", presentation.VideoPath, "-i", webcam.VideoPath, "-filter_complex", "[0:v]pad=width="+fmt.Sprint(width)+":height="+fmt.Sprint(height)+":color=white[p];[p][1:v]overlay=x="+fmt.Sprint(presentation.Width)+":y=0[out]", "-map", "[out]", "-map", "1:1", "-c:a", "aac", "-shortest", "-y", videoPath).Output()
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", presentation.VideoPath, "-i", webcam.VideoPath, "-filter_complex", "[0:v]pad=width=" + fmt.Sprint(width) + ":height=" + fmt.Sprint(height) + ":color=white[p];[p][1:v]overlay=x=" + fmt.Sprint(presentation.Width) + ":y=0[out]", "-map", "[out]", "-map", "1:1"}
+	cmd = append(cmd, VideoEncodeArgs(config.Encoding, config.Encoding.Tune)...)
+	cmd = append(cmd, "-c:a", "aac", "-shortest", "-y", videoPath)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func ProcessToEndExtension(input Video, config config.Data) error {
-	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath, "-y", config.OutputFile).Output()
+// AddChatPanel places the rendered chat panel on the right side of the video, the panel is scaled to the video height.
+func AddChatPanel(fullVideo Video, chat Video, config config.Data) (Video, error) {
+	videoPath := path.Join(config.WorkingDir, "out.chat.mp4")
+	filter := "[1:v]scale=-2:" + fmt.Sprint(fullVideo.Height) + ",setsar=1[c];[0:v]setsar=1[v];[v][c]hstack=inputs=2[out]"
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", fullVideo.VideoPath, "-i", chat.VideoPath, "-filter_complex", filter, "-map", "[out]", "-map", "0:a?"}
+	cmd = append(cmd, VideoEncodeArgs(config.Encoding, config.Encoding.Tune)...)
+	cmd = append(cmd, "-c:a", "copy", "-y", videoPath)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return Video{}, err
+	}
+	return GetVideoInfo(videoPath)
+}
+
+func ProcessToEndExtension(input Video, config config.Data, rendition config.Rendition) error {
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath, "-map", "0:v:0", "-map", "0:a?"}
+	if rendition.Height == 0 && input.Codec != "" && input.Codec == probeCodecs[rendition.Encoding.Codec] {
+		// The video already has the codec of the rendition, encoding it a second time only loses quality.
+		cmd = append(cmd, "-c:v", "copy")
+	} else {
+		cmd = append(cmd, ScaleArgs(rendition)...)
+		cmd = append(cmd, VideoEncodeArgs(rendition.Encoding, rendition.Encoding.Tune)...)
+	}
+	cmd = append(cmd, AudioEncodeArgs(rendition.OutputFile)...)
+	cmd = append(cmd, MovFlagsArgs(config, rendition.OutputFile)...)
+	cmd = append(cmd, "-y", rendition.OutputFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemuxOutput copies all streams into the rendition output without encoding, used to apply the -movflags.
+func RemuxOutput(input Video, config config.Data, rendition config.Rendition) error {
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath, "-map", "0", "-c", "copy"}
+	cmd = append(cmd, MovFlagsArgs(config, rendition.OutputFile)...)
+	cmd = append(cmd, "-y", rendition.OutputFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
 	if err != nil {
 		return err
 	}
@@ -7,29 +7,115 @@ import (
 	"io"
 	"os"
 	"path"
+	"time"
 )
 
 type Recording struct {
-	XMLName  xml.Name `xml:"recording"`
-	Playback Playback `xml:"playback"`
+	XMLName   xml.Name `xml:"recording"`
+	Id        string   `xml:"id"`
+	StartTime int64    `xml:"start_time"`
+	Meeting   Meeting  `xml:"meeting"`
+	Meta      Meta     `xml:"meta"`
+	Playback  Playback `xml:"playback"`
+}
+
+type Meeting struct {
+	XMLName xml.Name `xml:"meeting"`
+	Name    string   `xml:"name,attr"`
+}
+
+type Meta struct {
+	XMLName xml.Name    `xml:"meta"`
+	Entries []MetaEntry `xml:",any"`
+}
+
+type MetaEntry struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
 }
 
 type Playback struct {
 	XMLName  xml.Name `xml:"playback"`
+	Format   string   `xml:"format"`
 	Duration int      `xml:"duration"`
 }
 
 func GetDuration(config config.Data) (int, error) {
+	recording, err := loadRecording(config)
+	if err != nil {
+		return 0, err
+	}
+	return recording.Playback.Duration / 1000, nil
+}
+
+// GetPlaybackFormat returns the playback format of the recording (presentation, video, podcast, ...).
+func GetPlaybackFormat(config config.Data) (string, error) {
+	recording, err := loadRecording(config)
+	if err != nil {
+		return "", err
+	}
+	return recording.Playback.Format, nil
+}
+
+// GetMetadata returns the ffmpeg tags (title, album, date, comment) derived from the metadata.xml.
+func GetMetadata(config config.Data) (map[string]string, error) {
+	recording, err := loadRecording(config)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	tags := map[string]string{}
+	title := recording.Meta.Get("meetingName")
+	if title == "" {
+		title = recording.Meeting.Name
+	}
+	if title != "" {
+		tags["title"] = title
+	}
+	context := recording.Meta.Get("bbb-context-name")
+	if context == "" {
+		context = recording.Meta.Get("bbb-context")
+	}
+	if context != "" {
+		tags["album"] = context
+	}
+	if recording.StartTime > 0 {
+		tags["date"] = time.UnixMilli(recording.StartTime).UTC().Format("2006-01-02")
+	}
+	if recording.Id != "" {
+		tags["comment"] = "BigBlueButton recording " + recording.Id
+	}
+	return tags, nil
+}
+
+// GetMetaEntry returns the value of a meta parameter of the recording (e.g. meta_language on create is "language").
+func GetMetaEntry(config config.Data, name string) (string, error) {
+	recording, err := loadRecording(config)
+	if err != nil {
+		return "", err
+	}
+	return recording.Meta.Get(name), nil
+}
+
+func (m Meta) Get(name string) string {
+	for _, entry := range m.Entries {
+		if entry.XMLName.Local == name {
+			return entry.Value
+		}
+	}
+	return ""
+}
+
+func loadRecording(config config.Data) (Recording, error) {
 	xmlFile, err := os.Open(path.Join(config.RecordingDir, "metadata.xml"))
 	defer xmlFile.Close()
 	if err != nil {
-		return 0, errors.New("directory (" + config.RecordingDir + ") is not a bbb recording dir, the metadata.xml file is missing")
+		return Recording{}, errors.New("directory (" + config.RecordingDir + ") is not a bbb recording dir, the metadata.xml file is missing")
 	}
 	byteValue, _ := io.ReadAll(xmlFile)
 	var recording Recording
 	err = xml.Unmarshal(byteValue, &recording)
 	if err != nil {
-		return 0, err
+		return Recording{}, err
 	}
-	return recording.Playback.Duration / 1000, nil
+	return recording, nil
 }
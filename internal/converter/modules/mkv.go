@@ -0,0 +1,62 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WriteMKV muxes the encoded video into the matroska rendition output. The captions are taken from their WebVTT source
+// so the styling is kept (unless -mkv-subtitles converts them to srt or ass), chapters and tags come from the ffmpeg
+// metadata file and the presentation PDFs, the captions.json and the poster (as cover) are embedded as attachments.
+func WriteMKV(input Video, captions []Caption, metadataFile string, posterFile string, config config.Data, rendition config.Rendition) error {
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath}
+	for _, caption := range captions {
+		cmd = append(cmd, "-i", caption.Source)
+	}
+	if metadataFile != "" {
+		cmd = append(cmd, "-i", metadataFile)
+	}
+	cmd = append(cmd, "-map", "0:v:0", "-map", "0:a?")
+	for i := range captions {
+		cmd = append(cmd, "-map", fmt.Sprint(i+1)+":s")
+	}
+	if metadataFile != "" {
+		cmd = append(cmd, "-map_metadata", fmt.Sprint(len(captions)+1), "-map_chapters", fmt.Sprint(len(captions)+1))
+	}
+	cmd = append(cmd, "-c", "copy", "-c:s", SubtitleCodec(config, rendition.OutputFile))
+	cmd = append(cmd, CaptionMetadataArgs(captions)...)
+	attachments := mkvAttachments(config)
+	for i, attachment := range attachments {
+		mimetype := "application/pdf"
+		if filepath.Ext(attachment) == ".json" {
+			mimetype = "application/json"
+		}
+		cmd = append(cmd, "-attach", attachment, "-metadata:s:t:"+fmt.Sprint(i), "mimetype="+mimetype, "-metadata:s:t:"+fmt.Sprint(i), "filename="+filepath.Base(attachment))
+	}
+	if posterFile != "" {
+		// Players pick up the cover art of matroska files by the attachment name.
+		stream := "-metadata:s:t:" + fmt.Sprint(len(attachments))
+		cmd = append(cmd, "-attach", posterFile, stream, "mimetype=image/jpeg", stream, "filename=cover.jpg")
+	}
+	cmd = append(cmd, "-y", rendition.OutputFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// mkvAttachments returns the original presentation PDFs and the captions.json of the recording.
+func mkvAttachments(config config.Data) []string {
+	attachments, _ := filepath.Glob(path.Join(config.RecordingDir, "presentation", "*", "*.pdf"))
+	captionsFile := path.Join(config.RecordingDir, "captions.json")
+	_, err := os.Stat(captionsFile)
+	if err == nil {
+		attachments = append(attachments, captionsFile)
+	}
+	return attachments
+}
@@ -34,7 +34,18 @@ const (
 )
 
 func captureFrames(config config.Data, presentation Presentation) (map[float64]FrameInfo, error) {
-	opts := []chromedp.ExecAllocatorOption{
+	log.Println("Lets connect to the chrome instance...")
+	browserCtx, cancelA := chromedp.NewExecAllocator(context.Background(), browserOptions()...)
+	defer cancelA()
+
+	log.Println("Lets render the frames...")
+	frameInfos, err := renderFrames(browserCtx, config, presentation)
+	log.Println("Done.")
+	return frameInfos, err
+}
+
+func browserOptions() []chromedp.ExecAllocatorOption {
+	return []chromedp.ExecAllocatorOption{
 		chromedp.NoDefaultBrowserCheck,
 		chromedp.NoFirstRun,
 		chromedp.NoSandbox,
@@ -65,14 +76,6 @@ func captureFrames(config config.Data, presentation Presentation) (map[float64]F
 		chromedp.Flag("password-store", "basic"),
 		chromedp.Flag("use-mock-keychain", true),
 	}
-	log.Println("Lets connect to the chrome instance...")
-	browserCtx, cancelA := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancelA()
-
-	log.Println("Lets render the frames...")
-	frameInfos, err := renderFrames(browserCtx, config, presentation)
-	log.Println("Done.")
-	return frameInfos, err
 }
 
 func renderFrames(browserCtx context.Context, config config.Data, presentation Presentation) (map[float64]FrameInfo, error) {
@@ -127,6 +130,7 @@ func renderFrames(browserCtx context.Context, config config.Data, presentation P
 				chromedp.Navigate("file://" + path.Join(config.RecordingDir, "/shapes.svg")),
 				chromedp.ActionFunc(func(ctx context.Context) error {
 					defineFunctions(ctx)
+					_, _, _ = runtime.Evaluate(addCanvases(presentation.Canvases)).Do(ctx)
 					var wg sync.WaitGroup
 					actionString := ""
 					size := screenSize{0, 0}
@@ -0,0 +1,106 @@
+package presentation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/converter/modules"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+const chatPanelPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><style>
+html,body{margin:0;height:100%;overflow:hidden;background:#f3f6f9;font-family:"Noto Sans",sans-serif;font-size:14px;}
+#header{padding:10px 12px;background:#fff;border-bottom:1px solid #dce4ec;font-weight:bold;color:#06172a;}
+#messages{position:absolute;top:40px;bottom:0;left:0;right:0;display:flex;flex-direction:column;justify-content:flex-end;overflow:hidden;padding:0 12px 8px;}
+.message{margin-top:8px;color:#06172a;word-wrap:break-word;}
+.name{font-weight:bold;margin-right:4px;}
+.moderator .name{color:#0f70d7;}
+.time{color:#8b9aa8;font-size:11px;margin-left:4px;}
+</style></head><body><div id="header">Chat</div><div id="messages"></div></body></html>`
+
+// RenderChatPanel renders the chat like the BBB playback as a panel video, one frame per chat message.
+func RenderChatPanel(config config.Data, duration int, messages []modules.ChatMessage) modules.Video {
+	if len(messages) == 0 {
+		return modules.Video{}
+	}
+	start := time.Now()
+	pagePath := path.Join(config.WorkingDir, "chat.html")
+	err := os.WriteFile(pagePath, []byte(chatPanelPage), 0o644)
+	if err != nil {
+		return modules.Video{}
+	}
+	browserCtx, cancelA := chromedp.NewExecAllocator(context.Background(), browserOptions()...)
+	defer cancelA()
+	ctx, cancel := chromedp.NewContext(browserCtx)
+	defer cancel()
+	pres := Presentation{Frames: map[float64]Frame{}}
+	infos := map[float64]FrameInfo{}
+	capture := func(ctx context.Context, timestamp float64) error {
+		var buf []byte
+		err := chromedp.FullScreenshot(&buf, 90).Do(ctx)
+		if err != nil {
+			return err
+		}
+		framePath := path.Join(config.WorkingDir, "chat_"+fmt.Sprint(len(infos))+".png")
+		err = os.WriteFile(framePath, buf, 0o644)
+		if err != nil {
+			return err
+		}
+		pres.Frames[timestamp] = Frame{Timestamp: timestamp}
+		infos[timestamp] = FrameInfo{framePath, timestamp}
+		return nil
+	}
+	err = chromedp.Run(ctx, chromedp.Tasks{
+		chromedp.EmulateViewport(config.ChatPanelWidth, config.Height),
+		chromedp.Navigate("file://" + pagePath),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			_, _, _ = runtime.Evaluate("var messages=document.querySelector('#messages');" +
+				"function aM(name,text,time,moderator){let el=document.createElement('div');el.className='message'+(moderator?' moderator':'');" +
+				"let n=document.createElement('span');n.className='name';n.textContent=name;let t=document.createElement('span');t.className='time';t.textContent=time;" +
+				"let m=document.createElement('div');m.textContent=text;el.append(n,t,m);messages.append(el);" +
+				"while(messages.children.length>1&&messages.scrollHeight>messages.clientHeight){messages.firstChild.remove();}}").Do(ctx)
+			err := capture(ctx, 0)
+			if err != nil {
+				return err
+			}
+			for _, message := range messages {
+				name, _ := json.Marshal(message.Name)
+				text, _ := json.Marshal(message.Message)
+				stamp, _ := json.Marshal(modules.FormatChatTime(message.Time))
+				_, _, _ = runtime.Evaluate("aM(" + string(name) + "," + string(text) + "," + string(stamp) + "," + fmt.Sprint(message.Moderator) + ");").Do(ctx)
+				// Messages sent at the same time end up in one frame.
+				timestamp := message.Time
+				if timestamp == 0 {
+					timestamp = 0.001
+				}
+				err = capture(ctx, timestamp)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		log.Println("Could not render the chat panel: " + err.Error())
+		return modules.Video{}
+	}
+	video := renderVideo(pres, config, infos, duration, "chat")
+	if video.VideoPath == "" {
+		return video
+	}
+	end := time.Now().Sub(start)
+	log.Println("chat.mp4 creation took: " + fmt.Sprint(end))
+	info, err := modules.GetVideoInfo(video.VideoPath)
+	if err != nil {
+		return modules.Video{}
+	}
+	return info
+}
@@ -2,11 +2,14 @@ package presentation
 
 import (
 	"encoding/xml"
+	"fmt"
 	"github.com/cli-ish/bbb-video-converter/internal/config"
 	"github.com/cli-ish/bbb-video-converter/internal/converter/modules"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
 	"io"
 	"os"
 	"path"
+	"strings"
 )
 
 type deskshareData struct {
@@ -62,3 +65,28 @@ func parseDeskshares(config config.Data) deskshareData {
 	}
 	return deskshareData{}
 }
+
+// MixDeskshareAudio mixes the audio of the screen shares (recorded since BBB 2.4) into the webcam audio, the deskshare
+// audio is only used during the screen share intervals of deskshare.xml. Without deskshare audio the webcam video is
+// returned unchanged.
+func MixDeskshareAudio(webcam modules.Video, config config.Data) (modules.Video, error) {
+	deskData := parseDeskshares(config)
+	if webcam.VideoPath == "" || deskData.Video.VideoPath == "" || len(deskData.VideoParts) == 0 || !modules.HasAudio(deskData.Video.VideoPath) {
+		return webcam, nil
+	}
+	var intervals []string
+	for _, part := range deskData.VideoParts {
+		intervals = append(intervals, "between(t,"+fmt.Sprint(part.Start)+","+fmt.Sprint(part.End)+")")
+	}
+	filter := "[1:a:0]volume=volume='gt(" + strings.Join(intervals, "+") + ",0)':eval=frame[d];[0:a:0][d]amix=inputs=2:duration=first:normalize=0[a]"
+	videoPath := path.Join(config.WorkingDir, "webcams.mixed.mkv")
+	// The webcam video is only copied, the mixed audio stays lossless until the output is encoded.
+	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", webcam.VideoPath, "-i", deskData.Video.VideoPath,
+		"-filter_complex", filter, "-map", "0:v:0?", "-map", "[a]", "-c:v", "copy", "-c:a", "flac", "-y", videoPath).Output()
+	if err != nil {
+		return webcam, err
+	}
+	mixed := webcam
+	mixed.VideoPath = videoPath
+	return mixed, nil
+}
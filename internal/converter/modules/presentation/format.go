@@ -0,0 +1,124 @@
+package presentation
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/converter/modules"
+	"os"
+	"path"
+	"sync"
+)
+
+// RecordingFormat is the detected version of the BBB presentation format, the adapter parses its files into the
+// common Presentation model. The versions only differ in the whiteboard, the slides, panzooms, cursor and deskshare
+// files are read the same way for all of them. Warning is set if parts of the recording can not be rendered.
+type RecordingFormat struct {
+	Version  string
+	Playback string
+	Warning  string
+	adapter  formatAdapter
+}
+
+type formatAdapter interface {
+	parse(recordingDir string, duration int) Presentation
+}
+
+// legacyAdapter parses the presentation format up to BBB 2.5, the drawings are groups in shapes.svg.
+type legacyAdapter struct{}
+
+// tldrawAdapter parses the presentation format of BBB 2.6 and 2.7, the drawings of the tldraw whiteboard are stored
+// in tldraw.json, slides, panzooms and cursor are unchanged.
+type tldrawAdapter struct{}
+
+// DetectFormat identifies the format version from the metadata.xml and the files of the recording. Other playback
+// formats return an error, the tldraw 2 whiteboard of BBB 3.0 falls back to the slides without annotations. The video
+// and podcast formats are already rendered by BBB and have no slides to parse.
+func DetectFormat(config config.Data) (RecordingFormat, error) {
+	playback, err := modules.GetPlaybackFormat(config)
+	if err != nil {
+		return RecordingFormat{}, err
+	}
+	switch playback {
+	case "", "presentation":
+	case "video":
+		return RecordingFormat{Version: "BBB video format", Playback: playback}, nil
+	case "podcast":
+		return RecordingFormat{Version: "BBB podcast format", Playback: playback}, nil
+	default:
+		return RecordingFormat{}, errors.New("the playback format " + playback + " is not supported, only presentation, video and podcast recordings can be converted")
+	}
+	if !hasTldraw(config.RecordingDir) {
+		return RecordingFormat{Version: "BBB 2.0 - 2.5 (shapes.svg whiteboard)", Playback: "presentation", adapter: legacyAdapter{}}, nil
+	}
+	content, err := os.ReadFile(path.Join(config.RecordingDir, "tldraw.json"))
+	if err != nil {
+		return RecordingFormat{}, err
+	}
+	var slides map[string][]struct {
+		Shape map[string]json.RawMessage `json:"shape"`
+	}
+	err = json.Unmarshal(content, &slides)
+	if err != nil {
+		return RecordingFormat{}, errors.New("the tldraw.json of the recording has an unknown format, the BBB version is not supported")
+	}
+	for _, entries := range slides {
+		for _, entry := range entries {
+			// tldraw 2 moved the shape properties (size, color, ...) into props.
+			if _, ok := entry.Shape["props"]; ok {
+				return RecordingFormat{Version: "BBB 3.0 (tldraw 2 whiteboard)", Playback: "presentation", adapter: legacyAdapter{},
+					Warning: "the tldraw 2 whiteboard of BBB 3.0 recordings is not supported yet, the slides are converted without annotations"}, nil
+			}
+		}
+	}
+	return RecordingFormat{Version: "BBB 2.6 - 2.7 (tldraw whiteboard)", Playback: "presentation", adapter: tldrawAdapter{}}, nil
+}
+
+// HasSlides reports whether the slides, whiteboard and chat of the recording can be parsed.
+func (f RecordingFormat) HasSlides() bool {
+	return f.adapter != nil
+}
+
+// parseSlides parses the slides, whiteboard, panzooms and cursor through the adapter of the detected version.
+func (f RecordingFormat) parseSlides(recordingDir string, duration int) Presentation {
+	if f.adapter == nil {
+		return Presentation{Frames: map[float64]Frame{}}
+	}
+	return f.adapter.parse(recordingDir, duration)
+}
+
+func (legacyAdapter) parse(recordingDir string, duration int) Presentation {
+	var frames map[float64]Frame
+	var wg sync.WaitGroup
+	var width float64
+	var height float64
+	var panFrames map[float64]Frame
+	var panCursors map[float64]Frame
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		frames, width, height = parseShapes(recordingDir, duration)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		panFrames = parsePanzooms(recordingDir, duration)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		panCursors = parseCursors(recordingDir, duration)
+	}()
+	wg.Wait()
+	frames = mergeFrames(frames, panFrames)
+	frames = mergeFrames(frames, panCursors)
+	return Presentation{Frames: frames, Width: width, Height: height}
+}
+
+func (tldrawAdapter) parse(recordingDir string, duration int) Presentation {
+	pres := legacyAdapter{}.parse(recordingDir, duration)
+	tldrawFrames, canvases := parseTldraw(recordingDir, duration)
+	pres.Frames = mergeFrames(pres.Frames, tldrawFrames)
+	pres.Canvases = canvases
+	return pres
+}
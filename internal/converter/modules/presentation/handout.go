@@ -0,0 +1,130 @@
+package presentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotFunction returns the svg with only the given image and its annotations visible, cropped to the image.
+const snapshotFunction = "function sS(id){let copy=svgfile.cloneNode(true);let image=copy.querySelector('#'+id);" +
+	"copy.querySelectorAll('image').forEach(el=>{el.style.visibility=el===image?'visible':'hidden'});" +
+	"let number=id.match(/\\d+/);copy.querySelectorAll('[id^=canvas]').forEach(el=>{el.setAttribute('display',el.id==='canvas'+number?'block':'none')});" +
+	"let c=copy.querySelector('#cursor');if(c){c.remove();}" +
+	"copy.setAttribute('viewBox',[image.getAttribute('x')||0,image.getAttribute('y')||0,image.getAttribute('width'),image.getAttribute('height')].join(' '));" +
+	"copy.removeAttribute('id');copy.removeAttribute('style');copy.setAttribute('preserveAspectRatio','xMidYMid meet');return copy.outerHTML;}"
+
+// ExportSlidePDF writes <output>.slides.pdf with one page per slide, each page shows the annotations of the slide as
+// they were right before the slide was hidden the last time.
+func ExportSlidePDF(config config.Data, duration int, format RecordingFormat) error {
+	start := time.Now()
+	var spans []SlideSpan
+	for _, span := range GetSlideTimeline(config.RecordingDir, duration) {
+		if slideNumberRegex.MatchString(path.Base(span.ImagePath)) {
+			spans = append(spans, span)
+		}
+	}
+	if len(spans) == 0 {
+		return errors.New("the recording has no slides to export")
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].End < spans[j].End
+	})
+	pres := format.parseSlides(config.RecordingDir, duration)
+	frames, width, height := pres.Frames, pres.Width, pres.Height
+	timestamps := make([]float64, 0, len(frames))
+	for k := range frames {
+		timestamps = append(timestamps, k)
+	}
+	sort.Float64s(timestamps)
+
+	browserCtx, cancelA := chromedp.NewExecAllocator(context.Background(), browserOptions()...)
+	defer cancelA()
+	ctx, cancel := chromedp.NewContext(browserCtx)
+	defer cancel()
+	// A slide can be shown several times, the page keeps the position of its first appearance and the last state.
+	var order []string
+	pages := map[string]string{}
+	err := chromedp.Run(ctx, chromedp.Tasks{
+		chromedp.Navigate("file://" + path.Join(config.RecordingDir, "/shapes.svg")),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			defineFunctions(ctx)
+			_, _, _ = runtime.Evaluate(addCanvases(pres.Canvases) + snapshotFunction).Do(ctx)
+			next := 0
+			for _, span := range spans {
+				actionString := ""
+				for ; next < len(timestamps) && timestamps[next] < span.End; next++ {
+					for _, action := range frames[timestamps[next]].Actions {
+						switch action.Name {
+						case ShowDrawing:
+							actionString += showDrawing(action)
+						case HideDrawing:
+							actionString += hideDrawing(action)
+						}
+					}
+				}
+				_, _, _ = runtime.Evaluate(actionString).Do(ctx)
+				var svg string
+				err := chromedp.Evaluate("sS('"+span.Id+"')", &svg).Do(ctx)
+				if err != nil {
+					return err
+				}
+				if _, ok := pages[span.ImagePath]; !ok {
+					order = append(order, span.ImagePath)
+				}
+				pages[span.ImagePath] = svg
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		width, height = float64(config.Width), float64(config.Height)
+	}
+	content := "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><base href=\"file://" + config.RecordingDir + "/\">" +
+		"<style>@page{size:" + fmt.Sprint(width) + "px " + fmt.Sprint(height) + "px;margin:0}body{margin:0}" +
+		".page{width:" + fmt.Sprint(width) + "px;height:" + fmt.Sprint(height) + "px;overflow:hidden;break-after:page}" +
+		".page svg{display:block;width:100%;height:100%}</style></head><body>\n"
+	for _, imagePath := range order {
+		content += "<div class=\"page\">" + pages[imagePath] + "</div>\n"
+	}
+	content += "</body></html>\n"
+	handoutPath := path.Join(config.WorkingDir, "handout.html")
+	err = os.WriteFile(handoutPath, []byte(content), 0o644)
+	if err != nil {
+		return err
+	}
+	var pdf []byte
+	err = chromedp.Run(ctx, chromedp.Tasks{
+		chromedp.Navigate("file://" + handoutPath),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdf, _, err = page.PrintToPDF().WithPrintBackground(true).WithPreferCSSPageSize(true).Do(ctx)
+			return err
+		}),
+	})
+	if err != nil {
+		return err
+	}
+	pdfPath := strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile)) + ".slides.pdf"
+	err = os.WriteFile(pdfPath, pdf, 0o644)
+	if err != nil {
+		return err
+	}
+	end := time.Now().Sub(start)
+	log.Println("Slide PDF with " + fmt.Sprint(len(order)) + " pages took: " + fmt.Sprint(end))
+	return nil
+}
@@ -0,0 +1,100 @@
+package presentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type slideImage struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	File  string  `json:"file"`
+	Slide string  `json:"slide,omitempty"`
+}
+
+// exportSlideImages keeps the screenshot of every slide, drawing and panzoom change in <output>.slides/ together with
+// an index.json of the times they are shown. Cursor moves alone do not create a new image, identical states share one.
+func exportSlideImages(presentation Presentation, infos map[float64]FrameInfo, config config.Data, duration int) error {
+	outputDir := strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile)) + ".slides"
+	err := os.MkdirAll(outputDir, 0o755)
+	if err != nil {
+		return err
+	}
+	var timestamps []float64
+	for timestamp, frame := range presentation.Frames {
+		if timestamp >= float64(duration) {
+			continue
+		}
+		for _, action := range frame.Actions {
+			if action.Name != MoveCursor {
+				timestamps = append(timestamps, timestamp)
+				break
+			}
+		}
+	}
+	sort.Float64s(timestamps)
+	timeline := GetSlideTimeline(config.RecordingDir, duration)
+	files := map[string]string{}
+	var index []slideImage
+	for i, timestamp := range timestamps {
+		info, ok := infos[timestamp]
+		if !ok {
+			continue
+		}
+		end := float64(duration)
+		if i+1 < len(timestamps) {
+			end = timestamps[i+1]
+		}
+		if len(index) > 0 && files[info.FilePath] == index[len(index)-1].File {
+			index[len(index)-1].End = end
+			continue
+		}
+		name, ok := files[info.FilePath]
+		if !ok {
+			name = fmt.Sprintf("%04d.%s", len(files)+1, config.SlideImages)
+			err = saveSlideImage(info.FilePath, path.Join(outputDir, name))
+			if err != nil {
+				return err
+			}
+			files[info.FilePath] = name
+		}
+		entry := slideImage{Start: math.Round(timestamp*1000) / 1000, End: math.Round(end*1000) / 1000, File: name}
+		for _, span := range timeline {
+			if span.Start <= timestamp && timestamp < span.End {
+				entry.Slide = SlideTitle(span)
+			}
+		}
+		index = append(index, entry)
+	}
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path.Join(outputDir, "index.json"), content, 0o644)
+	if err != nil {
+		return err
+	}
+	log.Println("Exported " + fmt.Sprint(len(files)) + " slide images to " + outputDir)
+	return nil
+}
+
+func saveSlideImage(source string, target string) error {
+	if strings.HasSuffix(target, ".png") {
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0o644)
+	}
+	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-i", source, "-q:v", "2", "-y", target).Output()
+	return err
+}
@@ -0,0 +1,114 @@
+package presentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chromedp/chromedp"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/converter/modules"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"html"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+const titleCardPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><style>
+html,body{margin:0;height:100%%;background:#06172a;color:#fff;font-family:"Noto Sans",sans-serif;}
+body{display:flex;flex-direction:column;justify-content:center;padding:0 8%%;box-sizing:border-box;}
+h1{font-size:6vh;margin:0 0 2vh;line-height:1.2;}
+p{font-size:3vh;margin:0;color:#b8c7d6;}
+</style></head><body><h1>%s</h1><p>%s</p></body></html>`
+
+// CreatePoster writes the poster image of the configured source (first slide, most shown slide, title card or a frame
+// of the video at a fixed time) as poster.jpg into the working dir. Without slides the title card is used.
+func CreatePoster(config config.Data, duration int, video modules.Video) (string, error) {
+	posterPath := path.Join(config.WorkingDir, "poster.jpg")
+	source := ""
+	seek := 0.0
+	switch config.Poster {
+	case "first":
+		source = FirstSlideImage(GetSlideTimeline(config.RecordingDir, duration))
+	case "most":
+		source = mostShownSlide(GetSlideTimeline(config.RecordingDir, duration))
+	case "title":
+	default:
+		if video.VideoPath == "" || video.IsOnlyAudio {
+			return "", errors.New("the poster time needs a video, the recording has none")
+		}
+		source = video.VideoPath
+		seek = config.PosterTime
+	}
+	if source == "" {
+		log.Println("No slides found for the poster, using the title card")
+		titleCard, err := renderTitleCard(config)
+		if err != nil {
+			return "", err
+		}
+		source = titleCard
+	}
+	scale := "scale=w=" + fmt.Sprint(config.Width) + ":h=" + fmt.Sprint(config.Height) + ":force_original_aspect_ratio=decrease"
+	_, err := util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-ss", fmt.Sprint(seek), "-i", source, "-frames:v", "1", "-vf", scale, "-q:v", "2", "-y", posterPath).Output()
+	if err != nil {
+		return "", err
+	}
+	return posterPath, nil
+}
+
+// mostShownSlide returns the slide image which was on screen for the longest time in total.
+func mostShownSlide(spans []SlideSpan) string {
+	shown := map[string]float64{}
+	best := ""
+	for _, span := range spans {
+		if !slideNumberRegex.MatchString(path.Base(span.ImagePath)) {
+			continue
+		}
+		shown[span.ImagePath] += span.End - span.Start
+		if best == "" || shown[span.ImagePath] > shown[best] {
+			best = span.ImagePath
+		}
+	}
+	return best
+}
+
+func renderTitleCard(config config.Data) (string, error) {
+	tags, _ := modules.GetMetadata(config)
+	title := tags["title"]
+	if title == "" {
+		title = "BigBlueButton recording"
+	}
+	var parts []string
+	for _, key := range []string{"album", "date"} {
+		if tags[key] != "" {
+			parts = append(parts, tags[key])
+		}
+	}
+	details := strings.Join(parts, " · ")
+	pagePath := path.Join(config.WorkingDir, "title.html")
+	err := os.WriteFile(pagePath, []byte(fmt.Sprintf(titleCardPage, html.EscapeString(title), html.EscapeString(details))), 0o644)
+	if err != nil {
+		return "", err
+	}
+	browserCtx, cancelA := chromedp.NewExecAllocator(context.Background(), browserOptions()...)
+	defer cancelA()
+	ctx, cancel := chromedp.NewContext(browserCtx)
+	defer cancel()
+	var buf []byte
+	err = chromedp.Run(ctx, chromedp.Tasks{
+		chromedp.EmulateViewport(config.Width, config.Height),
+		chromedp.Navigate("file://" + pagePath),
+		chromedp.FullScreenshot(&buf, 90),
+	})
+	if err != nil {
+		return "", err
+	}
+	titlePath := path.Join(config.WorkingDir, "title.png")
+	err = os.WriteFile(titlePath, buf, 0o644)
+	if err != nil {
+		return "", err
+	}
+	return titlePath, nil
+}
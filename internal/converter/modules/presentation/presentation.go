@@ -12,14 +12,14 @@ import (
 	"time"
 )
 
-func CreatePresentationVideo(config config.Data, duration int) modules.Video {
+func CreatePresentationVideo(config config.Data, duration int, format RecordingFormat) modules.Video {
 	var wg sync.WaitGroup
 	var slideVideo modules.Video
 	var deskData deskshareData
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		slideVideo = renderSlides(config, duration)
+		slideVideo = renderSlides(config, duration, format)
 	}()
 	wg.Add(1)
 	go func() {
@@ -59,7 +59,10 @@ func combinedSlidesAndDeskshares(slideVideo modules.Video, deskData deskshareDat
 	resizedDeskshareVideo := path.Join(config.WorkingDir, "deskshare.mp4")
 	presentationOut := path.Join(config.WorkingDir, "presentation.mp4")
 	presentationTmp := path.Join(config.WorkingDir, "presentation.tmp.mp4")
-	_, err = util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", deskData.Video.VideoPath, "-vf", "scale=w="+fmt.Sprint(info.Width)+":h="+fmt.Sprint(info.Height)+":force_original_aspect_ratio=1,pad="+fmt.Sprint(info.Width)+":"+fmt.Sprint(info.Height)+":(ow-iw)/2:(oh-ih)/2:color=white", "-c:v", "libx264", "-preset", "ultrafast", resizedDeskshareVideo).Output()
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", deskData.Video.VideoPath, "-vf", "scale=w=" + fmt.Sprint(info.Width) + ":h=" + fmt.Sprint(info.Height) + ":force_original_aspect_ratio=1,pad=" + fmt.Sprint(info.Width) + ":" + fmt.Sprint(info.Height) + ":(ow-iw)/2:(oh-ih)/2:color=white"}
+	cmd = append(cmd, modules.VideoEncodeArgs(config.Encoding, config.Encoding.Tune)...)
+	cmd = append(cmd, resizedDeskshareVideo)
+	_, err = util.ExecuteCommand("ffmpeg", cmd...).Output()
 	if err != nil {
 		return modules.Video{}
 	}
@@ -68,7 +71,10 @@ func combinedSlidesAndDeskshares(slideVideo modules.Video, deskData deskshareDat
 		if i != 0 {
 			presIn = presentationOut
 		}
-		_, err = util.ExecuteCommand("ffmpeg", "-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", presIn, "-i", resizedDeskshareVideo, "-filter_complex", "[0][1]overlay=x=0:y=0:enable='between(t,"+fmt.Sprint(v.Start)+","+fmt.Sprint(v.End)+")'[out]", "-map", "[out]", "-c:a", "copy", "-c:v", "libx264", "-preset", "ultrafast", presentationTmp).Output()
+		cmd = []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", presIn, "-i", resizedDeskshareVideo, "-filter_complex", "[0][1]overlay=x=0:y=0:enable='between(t," + fmt.Sprint(v.Start) + "," + fmt.Sprint(v.End) + ")'[out]", "-map", "[out]", "-c:a", "copy"}
+		cmd = append(cmd, modules.VideoEncodeArgs(config.Encoding, config.Encoding.Tune)...)
+		cmd = append(cmd, presentationTmp)
+		_, err = util.ExecuteCommand("ffmpeg", cmd...).Output()
 		if err != nil {
 			return modules.Video{}
 		}
@@ -0,0 +1,85 @@
+package presentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type searchRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+type searchEntry struct {
+	Slide        string        `json:"slide"`
+	Presentation string        `json:"presentation"`
+	Text         string        `json:"text"`
+	Ranges       []searchRange `json:"ranges"`
+}
+
+// WriteSearchIndex writes <output>.search.json which maps the extracted text of every slide (from the textfiles BBB
+// publishes next to the slide images) to the time ranges the slide was on screen.
+func WriteSearchIndex(config config.Data, duration int) error {
+	entries := []searchEntry{}
+	indexes := map[string]int{}
+	for _, span := range GetSlideTimeline(config.RecordingDir, duration) {
+		if !slideNumberRegex.MatchString(path.Base(span.ImagePath)) {
+			continue
+		}
+		i, ok := indexes[span.ImagePath]
+		if !ok {
+			text, err := readSlideText(span)
+			if err != nil {
+				continue
+			}
+			i = len(entries)
+			indexes[span.ImagePath] = i
+			entries = append(entries, searchEntry{
+				Slide:        SlideTitle(span),
+				Presentation: path.Base(path.Dir(span.ImagePath)),
+				Text:         text,
+				Ranges:       []searchRange{},
+			})
+		}
+		ranges := entries[i].Ranges
+		start := math.Round(span.Start*1000) / 1000
+		end := math.Round(span.End*1000) / 1000
+		if len(ranges) > 0 && ranges[len(ranges)-1].End >= start {
+			ranges[len(ranges)-1].End = end
+		} else {
+			ranges = append(ranges, searchRange{start, end})
+		}
+		entries[i].Ranges = ranges
+	}
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile))+".search.json", content, 0o644)
+	if err != nil {
+		return err
+	}
+	log.Println("Wrote search index with " + fmt.Sprint(len(entries)) + " slides")
+	return nil
+}
+
+// readSlideText reads the text of the slide, older recordings have no text attribute and use textfiles/slide-N.txt.
+func readSlideText(span SlideSpan) (string, error) {
+	textPath := span.TextPath
+	if textPath == "" {
+		name := path.Base(span.ImagePath)
+		textPath = path.Join(path.Dir(span.ImagePath), "textfiles", strings.TrimSuffix(name, path.Ext(name))+".txt")
+	}
+	content, err := os.ReadFile(textPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(string(content)), " "), nil
+}
@@ -29,6 +29,8 @@ type image struct {
 	Id      string   `xml:"id,attr"`
 	Width   int      `xml:"width,attr"`
 	Height  int      `xml:"height,attr"`
+	Href    string   `xml:"href,attr"`
+	Text    string   `xml:"text,attr"`
 }
 type drawing struct {
 	XMLName   xml.Name `xml:"g"`
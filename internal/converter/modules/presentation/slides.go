@@ -10,14 +10,14 @@ import (
 	"os"
 	"path"
 	"sort"
-	"sync"
 	"time"
 )
 
 type Presentation struct {
-	Frames map[float64]Frame
-	Width  float64
-	Height float64
+	Frames   map[float64]Frame
+	Width    float64
+	Height   float64
+	Canvases map[string]string
 }
 
 type Frame struct {
@@ -42,8 +42,8 @@ const (
 	HideDrawing string = "hideDrawing"
 )
 
-func renderSlides(config config.Data, duration int) modules.Video {
-	presentation := parseSlidesData(config.RecordingDir, duration)
+func renderSlides(config config.Data, duration int, format RecordingFormat) modules.Video {
+	presentation := format.parseSlides(config.RecordingDir, duration)
 	if len(presentation.Frames) > 1 {
 		start := time.Now()
 		infos, err := captureFrames(config, presentation)
@@ -52,8 +52,14 @@ func renderSlides(config config.Data, duration int) modules.Video {
 		}
 		end := time.Now().Sub(start)
 		log.Println("slide generation took: " + fmt.Sprint(end))
+		if config.SlideImages != "" {
+			err = exportSlideImages(presentation, infos, config, duration)
+			if err != nil {
+				log.Println("Could not export the slide images: " + err.Error())
+			}
+		}
 		start = time.Now()
-		video := renderVideo(presentation, config, infos, duration)
+		video := renderVideo(presentation, config, infos, duration, "slides")
 		end = time.Now().Sub(start)
 		log.Println("slide.mp4 creation took: " + fmt.Sprint(end))
 		return video
@@ -61,7 +67,7 @@ func renderSlides(config config.Data, duration int) modules.Video {
 	return modules.Video{}
 }
 
-func renderVideo(presentation Presentation, config config.Data, infos map[float64]FrameInfo, durationReal int) modules.Video {
+func renderVideo(presentation Presentation, config config.Data, infos map[float64]FrameInfo, durationReal int, name string) modules.Video {
 	frames := presentation.Frames
 	timestamps := make([]float64, 0, len(frames))
 	for k := range frames {
@@ -76,7 +82,7 @@ func renderVideo(presentation Presentation, config config.Data, infos map[float6
 			slidesContent += "duration " + fmt.Sprint(duration) + "\n"
 		}
 	}
-	slidesTxtFile := path.Join(config.WorkingDir, "slides.txt")
+	slidesTxtFile := path.Join(config.WorkingDir, name+".txt")
 	file, err := os.Create(slidesTxtFile)
 	if err != nil {
 		return modules.Video{}
@@ -87,43 +93,17 @@ func renderVideo(presentation Presentation, config config.Data, infos map[float6
 		return modules.Video{}
 	}
 	result := modules.Video{}
-	result.VideoPath = path.Join(config.WorkingDir, "slides.mp4")
-	_, err = util.ExecuteCommand("ffmpeg", "-safe", "0", "-hide_banner", "-loglevel", "error", "-f", "concat", "-i", slidesTxtFile, "-threads", config.ThreadCount, "-y", "-strict", "-2", "-crf", "22", "-preset", "ultrafast", "-t", fmt.Sprint(durationReal), "-c:v", "libx264", "-pix_fmt", "yuv420p", result.VideoPath).Output()
+	result.VideoPath = path.Join(config.WorkingDir, name+".mp4")
+	cmd := []string{"-safe", "0", "-hide_banner", "-loglevel", "error", "-f", "concat", "-i", slidesTxtFile, "-threads", config.ThreadCount, "-y", "-strict", "-2", "-t", fmt.Sprint(durationReal)}
+	cmd = append(cmd, modules.VideoEncodeArgs(config.Encoding, config.Encoding.SlideTune)...)
+	cmd = append(cmd, result.VideoPath)
+	_, err = util.ExecuteCommand("ffmpeg", cmd...).Output()
 	if err != nil {
 		return modules.Video{}
 	}
 	return result
 }
 
-func parseSlidesData(recordingDir string, duration int) Presentation {
-	var frames map[float64]Frame
-	var wg sync.WaitGroup
-	var width float64
-	var height float64
-	var panFrames map[float64]Frame
-	var panCursors map[float64]Frame
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		frames, width, height = parseShapes(recordingDir, duration)
-	}()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		panFrames = parsePanzooms(recordingDir, duration)
-	}()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		panCursors = parseCursors(recordingDir, duration)
-	}()
-	wg.Wait()
-	frames = mergeFrames(frames, panFrames)
-	frames = mergeFrames(frames, panCursors)
-	pres := Presentation{frames, width, height}
-	return pres
-}
-
 func mergeFrames(old map[float64]Frame, newFrame map[float64]Frame) map[float64]Frame {
 	for key, value := range newFrame {
 		_, ok := old[key]
@@ -0,0 +1,104 @@
+package presentation
+
+import (
+	"encoding/xml"
+	"github.com/cli-ish/bbb-video-converter/internal/converter/modules"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type SlideSpan struct {
+	Id        string
+	ImagePath string
+	TextPath  string
+	Start     float64
+	End       float64
+}
+
+var slideNumberRegex = regexp.MustCompile(`slide-(\d+)\.`)
+
+// GetSlideTimeline returns the images shown in shapes.svg ordered by the time they appear on screen.
+func GetSlideTimeline(recordingDir string, duration int) []SlideSpan {
+	shapeFile, err := os.Open(path.Join(recordingDir, "shapes.svg"))
+	if err != nil {
+		return []SlideSpan{}
+	}
+	defer shapeFile.Close()
+	byteValue, _ := io.ReadAll(shapeFile)
+	var shapes shapes
+	err = xml.Unmarshal(byteValue, &shapes)
+	if err != nil {
+		return []SlideSpan{}
+	}
+	var spans []SlideSpan
+	for _, image := range shapes.Images {
+		if image.In >= float64(duration) || image.Href == "" {
+			continue
+		}
+		end := math.Min(image.Out, float64(duration))
+		if end <= image.In {
+			continue
+		}
+		textPath := ""
+		if image.Text != "" {
+			textPath = path.Join(recordingDir, image.Text)
+		}
+		spans = append(spans, SlideSpan{
+			Id:        image.Id,
+			ImagePath: path.Join(recordingDir, image.Href),
+			TextPath:  textPath,
+			Start:     image.In,
+			End:       end,
+		})
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start < spans[j].Start
+	})
+	return spans
+}
+
+// SlideChapters turns the slide timeline into chapters, consecutive spans of the same image are merged.
+func SlideChapters(spans []SlideSpan) []modules.Chapter {
+	var chapters []modules.Chapter
+	lastImage := ""
+	for _, span := range spans {
+		if span.ImagePath == lastImage && len(chapters) > 0 {
+			chapters[len(chapters)-1].End = span.End
+			continue
+		}
+		chapters = append(chapters, modules.Chapter{
+			Start: span.Start,
+			End:   span.End,
+			Title: SlideTitle(span),
+		})
+		lastImage = span.ImagePath
+	}
+	return chapters
+}
+
+// FirstSlideImage returns the first real slide image of the timeline, deskshare and logo placeholders are skipped.
+func FirstSlideImage(spans []SlideSpan) string {
+	for _, span := range spans {
+		if slideNumberRegex.MatchString(path.Base(span.ImagePath)) {
+			return span.ImagePath
+		}
+	}
+	return ""
+}
+
+func SlideTitle(span SlideSpan) string {
+	name := path.Base(span.ImagePath)
+	match := slideNumberRegex.FindStringSubmatch(name)
+	if match != nil {
+		return "Slide " + match[1]
+	}
+	if strings.HasPrefix(name, "deskshare") {
+		return "Screen share"
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
@@ -0,0 +1,235 @@
+package presentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BBB 2.6+ records the tldraw whiteboard into tldraw.json instead of drawing groups in shapes.svg. The entries are
+// grouped by the slide image, every update of a shape is a new entry with the same shape id.
+type tldrawEntry struct {
+	Id        string      `json:"id"`
+	Timestamp float64     `json:"timestamp"`
+	Undo      float64     `json:"undo"`
+	Shape     tldrawShape `json:"shape"`
+}
+
+type tldrawShape struct {
+	Type     string                  `json:"type"`
+	Point    []float64               `json:"point"`
+	Size     []float64               `json:"size"`
+	Points   [][]float64             `json:"points"`
+	Rotation float64                 `json:"rotation"`
+	Text     string                  `json:"text"`
+	Handles  map[string]tldrawHandle `json:"handles"`
+	Style    tldrawStyle             `json:"style"`
+}
+
+type tldrawHandle struct {
+	Point []float64 `json:"point"`
+}
+
+type tldrawStyle struct {
+	Color    string  `json:"color"`
+	Size     string  `json:"size"`
+	Dash     string  `json:"dash"`
+	IsFilled bool    `json:"isFilled"`
+	Scale    float64 `json:"scale"`
+}
+
+// tldrawColors are the stroke colors of the tldraw palette.
+var tldrawColors = map[string]string{
+	"white": "#f8f9fa", "lightGray": "#ced4da", "gray": "#868e96", "black": "#1d1d1d",
+	"green": "#36b24d", "cyan": "#0e98ad", "blue": "#1c7ed6", "indigo": "#4263eb",
+	"violet": "#7746f1", "red": "#ff2133", "orange": "#ff9433", "yellow": "#ffc936",
+}
+
+var tldrawStrokeWidths = map[string]float64{"small": 2, "medium": 3.5, "large": 5}
+
+var tldrawFontSizes = map[string]float64{"small": 28, "medium": 48, "large": 96}
+
+var digitsRegex = regexp.MustCompile(`\d+`)
+
+// hasTldraw reports whether the whiteboard of the recording was recorded with tldraw.
+func hasTldraw(recordingDir string) bool {
+	_, err := os.Stat(path.Join(recordingDir, "tldraw.json"))
+	return err == nil
+}
+
+// parseTldraw turns tldraw.json into show and hide drawing actions and the svg groups (one canvas per slide image)
+// which are added to shapes.svg, so they are shown and hidden by the same functions as the legacy drawings.
+func parseTldraw(recordingDir string, duration int) (map[float64]Frame, map[string]string) {
+	frames := map[float64]Frame{}
+	canvases := map[string]string{}
+	tldrawFile, err := os.Open(path.Join(recordingDir, "tldraw.json"))
+	if err != nil {
+		return frames, canvases
+	}
+	defer tldrawFile.Close()
+	byteValue, _ := io.ReadAll(tldrawFile)
+	var slides map[string][]tldrawEntry
+	err = json.Unmarshal(byteValue, &slides)
+	if err != nil {
+		return frames, canvases
+	}
+	keys := make([]string, 0, len(slides))
+	for key := range slides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	count := 0
+	for _, key := range keys {
+		// The slides are keyed by the image (image3 or 3), the canvas uses the number like the legacy drawings.
+		number := digitsRegex.FindString(key)
+		if number == "" {
+			continue
+		}
+		markup := ""
+		for _, entry := range slides[key] {
+			if entry.Timestamp >= float64(duration) {
+				continue
+			}
+			element := tldrawElement(entry.Shape)
+			if element == "" {
+				continue
+			}
+			count++
+			id := "tldraw" + fmt.Sprint(count)
+			markup += "<g id=\"" + id + "\" shape=\"tldraw-" + html.EscapeString(entry.Id) + "\" style=\"visibility:hidden\">" + element + "</g>"
+			frame := frames[entry.Timestamp]
+			frame.Timestamp = entry.Timestamp
+			frame.Actions = append(frame.Actions, Action{Name: ShowDrawing, Id: id})
+			frames[entry.Timestamp] = frame
+			if entry.Undo > 0 && entry.Undo < float64(duration) {
+				frame = frames[entry.Undo]
+				frame.Timestamp = entry.Undo
+				frame.Actions = append(frame.Actions, Action{Name: HideDrawing, Id: id})
+				frames[entry.Undo] = frame
+			}
+		}
+		canvases[number] += markup
+	}
+	return frames, canvases
+}
+
+// tldrawElement renders a tldraw shape as svg, unknown shape types are skipped.
+func tldrawElement(shape tldrawShape) string {
+	scale := shape.Style.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	color, ok := tldrawColors[shape.Style.Color]
+	if !ok {
+		color = tldrawColors["black"]
+	}
+	strokeWidth := tldrawStrokeWidths[shape.Style.Size] * scale
+	if strokeWidth <= 0 {
+		strokeWidth = tldrawStrokeWidths["medium"] * scale
+	}
+	width, height := 0.0, 0.0
+	if len(shape.Size) == 2 {
+		width, height = shape.Size[0], shape.Size[1]
+	}
+	fill := "fill=\"none\""
+	if shape.Style.IsFilled {
+		fill = "fill=\"" + color + "\" fill-opacity=\"0.3\""
+	}
+	stroke := "stroke=\"" + color + "\" stroke-width=\"" + fmt.Sprint(strokeWidth) + "\" stroke-linecap=\"round\" stroke-linejoin=\"round\""
+	switch shape.Style.Dash {
+	case "dashed":
+		stroke += " stroke-dasharray=\"" + fmt.Sprint(strokeWidth*2) + " " + fmt.Sprint(strokeWidth*2) + "\""
+	case "dotted":
+		stroke += " stroke-dasharray=\"0 " + fmt.Sprint(strokeWidth*2) + "\""
+	}
+	element := ""
+	switch shape.Type {
+	case "draw":
+		if len(shape.Points) == 0 {
+			return ""
+		}
+		var commands []string
+		for _, point := range shape.Points {
+			if len(point) < 2 {
+				continue
+			}
+			command := "L"
+			if len(commands) == 0 {
+				command = "M"
+			}
+			commands = append(commands, command+fmt.Sprint(point[0])+" "+fmt.Sprint(point[1]))
+		}
+		// A single point is a dot, the path needs a second point to be drawn.
+		if len(commands) == 1 {
+			commands = append(commands, "l0 0.01")
+		}
+		element = "<path d=\"" + strings.Join(commands, " ") + "\" fill=\"none\" " + stroke + "/>"
+	case "rectangle":
+		element = "<rect width=\"" + fmt.Sprint(width) + "\" height=\"" + fmt.Sprint(height) + "\" " + fill + " " + stroke + "/>"
+	case "ellipse":
+		element = "<ellipse cx=\"" + fmt.Sprint(width/2) + "\" cy=\"" + fmt.Sprint(height/2) + "\" rx=\"" + fmt.Sprint(width/2) +
+			"\" ry=\"" + fmt.Sprint(height/2) + "\" " + fill + " " + stroke + "/>"
+	case "triangle":
+		element = "<polygon points=\"" + fmt.Sprint(width/2) + ",0 " + fmt.Sprint(width) + "," + fmt.Sprint(height) + " 0," +
+			fmt.Sprint(height) + "\" " + fill + " " + stroke + "/>"
+	case "arrow":
+		start, okStart := shape.Handles["start"]
+		end, okEnd := shape.Handles["end"]
+		if !okStart || !okEnd || len(start.Point) < 2 || len(end.Point) < 2 {
+			return ""
+		}
+		x1, y1, x2, y2 := start.Point[0], start.Point[1], end.Point[0], end.Point[1]
+		angle := math.Atan2(y2-y1, x2-x1)
+		head := strokeWidth * 4
+		element = "<path d=\"M" + fmt.Sprint(x1) + " " + fmt.Sprint(y1) + " L" + fmt.Sprint(x2) + " " + fmt.Sprint(y2) +
+			" M" + fmt.Sprint(x2-head*math.Cos(angle-math.Pi/6)) + " " + fmt.Sprint(y2-head*math.Sin(angle-math.Pi/6)) +
+			" L" + fmt.Sprint(x2) + " " + fmt.Sprint(y2) +
+			" L" + fmt.Sprint(x2-head*math.Cos(angle+math.Pi/6)) + " " + fmt.Sprint(y2-head*math.Sin(angle+math.Pi/6)) +
+			"\" fill=\"none\" " + stroke + "/>"
+	case "text", "sticky":
+		fontSize := tldrawFontSizes[shape.Style.Size] * scale
+		if fontSize <= 0 {
+			fontSize = tldrawFontSizes["medium"] * scale
+		}
+		if shape.Type == "sticky" {
+			element = "<rect width=\"" + fmt.Sprint(width) + "\" height=\"" + fmt.Sprint(height) + "\" fill=\"" + tldrawColors["yellow"] + "\"/>"
+			color = tldrawColors["black"]
+			fontSize /= 2
+		}
+		element += "<text fill=\"" + color + "\" font-family=\"Noto Sans,sans-serif\" font-size=\"" + fmt.Sprint(fontSize) + "\">"
+		for i, line := range strings.Split(shape.Text, "\n") {
+			element += "<tspan x=\"0\" y=\"" + fmt.Sprint(fontSize*(float64(i)+1)) + "\">" + html.EscapeString(line) + "</tspan>"
+		}
+		element += "</text>"
+	default:
+		return ""
+	}
+	transform := ""
+	if len(shape.Point) == 2 {
+		transform = "translate(" + fmt.Sprint(shape.Point[0]) + " " + fmt.Sprint(shape.Point[1]) + ")"
+	}
+	if shape.Rotation != 0 {
+		transform += " rotate(" + fmt.Sprint(shape.Rotation*180/math.Pi) + " " + fmt.Sprint(width/2) + " " + fmt.Sprint(height/2) + ")"
+	}
+	return "<g transform=\"" + strings.TrimSpace(transform) + "\">" + element + "</g>"
+}
+
+// addCanvases adds the svg groups of the tldraw shapes to the canvas of their slide image.
+func addCanvases(canvases map[string]string) string {
+	script := ""
+	for number, markup := range canvases {
+		content, _ := json.Marshal(markup)
+		script += "(function(){let canvas=document.querySelector('#canvas" + number + "');if(!canvas){svgfile.insertAdjacentHTML('beforeend'," +
+			"'<g class=\"canvas\" id=\"canvas" + number + "\" image=\"image" + number + "\" display=\"none\"></g>');canvas=document.querySelector('#canvas" +
+			number + "');}" +
+			"canvas.insertAdjacentHTML('beforeend'," + string(content) + ");})();"
+	}
+	return script
+}
@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// thumbnailColumns and thumbnailRows are the grid of one sprite sheet.
+const (
+	thumbnailColumns = 10
+	thumbnailRows    = 10
+)
+
+// WriteThumbnails writes seek preview sprite sheets of the video into <output>.thumbnails/ together with the
+// thumbnails.vtt which maps each interval to its region (sprite-001.jpg#xywh=x,y,w,h) as video.js and Plyr use it.
+func WriteThumbnails(video Video, config config.Data, duration int) error {
+	outputDir := strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile)) + ".thumbnails"
+	err := os.MkdirAll(outputDir, 0o755)
+	if err != nil {
+		return err
+	}
+	width := int64(config.ThumbnailWidth)
+	height := int64(math.Round(float64(width)*video.Height/video.Width/2) * 2)
+	filter := "fps=1/" + fmt.Sprint(config.ThumbnailInterval) + ",scale=" + fmt.Sprint(width) + ":" + fmt.Sprint(height) +
+		",tile=" + fmt.Sprint(thumbnailColumns) + "x" + fmt.Sprint(thumbnailRows)
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", video.VideoPath, "-an", "-sn",
+		"-vf", filter, "-q:v", "4", "-start_number", "1", "-y", path.Join(outputDir, "sprite-%03d.jpg")}
+	_, err = util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	vtt := vttFile{}
+	perSheet := thumbnailColumns * thumbnailRows
+	for i := 0; float64(i*config.ThumbnailInterval) < float64(duration); i++ {
+		cell := i % perSheet
+		x := int64(cell%thumbnailColumns) * width
+		y := int64(cell/thumbnailColumns) * height
+		vtt.Cues = append(vtt.Cues, cue{
+			Start: float64(i * config.ThumbnailInterval),
+			End:   math.Min(float64((i+1)*config.ThumbnailInterval), float64(duration)),
+			Text:  fmt.Sprintf("sprite-%03d.jpg#xywh=%d,%d,%d,%d", i/perSheet+1, x, y, width, height),
+		})
+	}
+	return os.WriteFile(path.Join(outputDir, "thumbnails.vtt"), []byte(vtt.String()), 0o644)
+}
@@ -0,0 +1,107 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type transcriptMessage struct {
+	Time      float64 `json:"time"`
+	Timestamp string  `json:"timestamp"`
+	Name      string  `json:"name"`
+	Message   string  `json:"message"`
+	Moderator bool    `json:"moderator"`
+}
+
+type transcript struct {
+	Title    string              `json:"title,omitempty"`
+	Video    string              `json:"video,omitempty"`
+	Messages []transcriptMessage `json:"messages"`
+}
+
+// WriteChatTranscript writes the chat as <output>.chat.<format> next to the output in the configured formats. The times
+// are relative to the start of the output, the html transcript links every message to its position in the first
+// written video (or the audio export). Streaming manifests can not be opened with a media fragment, they get no links.
+func WriteChatTranscript(messages []ChatMessage, title string, config config.Data) error {
+	base := strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile))
+	video := ""
+	if config.AudioOnly {
+		video = filepath.Base(AudioOutputFile(config))
+	} else if !config.IsStreaming() && len(config.Renditions) > 0 {
+		video = filepath.Base(config.Renditions[0].OutputFile)
+	}
+	t := transcript{Title: title, Video: video, Messages: []transcriptMessage{}}
+	for _, message := range messages {
+		t.Messages = append(t.Messages, transcriptMessage{
+			Time:      math.Round(message.Time*1000) / 1000,
+			Timestamp: FormatChatTime(message.Time),
+			Name:      message.Name,
+			Message:   message.Message,
+			Moderator: message.Moderator,
+		})
+	}
+	for _, format := range config.ChatTranscript {
+		var content []byte
+		var err error
+		switch format {
+		case "html":
+			content = []byte(t.html())
+		case "txt":
+			content = []byte(t.text())
+		case "json":
+			content, err = json.MarshalIndent(t, "", "  ")
+			if err != nil {
+				return err
+			}
+		}
+		err = os.WriteFile(base+".chat."+format, content, 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t transcript) text() string {
+	content := ""
+	if t.Title != "" {
+		content += t.Title + "\n\n"
+	}
+	for _, message := range t.Messages {
+		content += "[" + message.Timestamp + "] " + message.Name + ": " + message.Message + "\n"
+	}
+	return content
+}
+
+func (t transcript) html() string {
+	title := "Chat"
+	if t.Title != "" {
+		title = t.Title + " - Chat"
+	}
+	content := "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + html.EscapeString(title) + "</title>\n" +
+		"<style>body{font-family:sans-serif;max-width:800px;margin:2em auto;color:#06172a;}" +
+		".message{margin:.5em 0;}.time{color:#8b9aa8;text-decoration:none;margin-right:.5em;}" +
+		".name{font-weight:bold;margin-right:.25em;}.moderator .name{color:#0f70d7;}</style></head>\n" +
+		"<body><h1>" + html.EscapeString(title) + "</h1>\n"
+	for _, message := range t.Messages {
+		class := "message"
+		if message.Moderator {
+			class += " moderator"
+		}
+		// Media fragments (#t=) let the browser start the video at the time of the message.
+		timestamp := "<span class=\"time\">" + message.Timestamp + "</span>"
+		if t.Video != "" {
+			timestamp = "<a class=\"time\" href=\"" + html.EscapeString(t.Video) + "#t=" + fmt.Sprint(message.Time) + "\">" + message.Timestamp + "</a>"
+		}
+		content += "<div class=\"" + class + "\">" + timestamp +
+			"<span class=\"name\">" + html.EscapeString(message.Name) + ":</span>" +
+			"<span class=\"text\">" + html.EscapeString(message.Message) + "</span></div>\n"
+	}
+	return content + "</body></html>\n"
+}
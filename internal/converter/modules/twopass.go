@@ -0,0 +1,132 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/util"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// containerOverhead is the share of the file size reserved for the container and bitrate deviations.
+const containerOverhead = 0.03
+
+// TargetVideoBitrate returns the video bitrate for the output, calculated from -max-size or given by -target-bitrate.
+func TargetVideoBitrate(config config.Data, rendition config.Rendition, duration int) (int64, error) {
+	if config.TargetBitrate > 0 {
+		if config.IsStreaming() {
+			return ladderBitrate(config, rendition), nil
+		}
+		return config.TargetBitrate, nil
+	}
+	if config.MaxSize == 0 {
+		return 0, nil
+	}
+	if duration <= 0 {
+		return 0, errors.New("can not calculate a bitrate for the max size, the recording has no duration")
+	}
+	// The attachments of matroska outputs are part of the file, they reduce the size left for the streams.
+	available := float64(config.MaxSize)*(1-containerOverhead) - float64(attachmentSize(config, rendition))
+	totalBitrate := available * 8 / float64(duration)
+	bitrate := int64(totalBitrate) - audioBitrate(rendition.OutputFile)
+	if bitrate < config.MinBitrate {
+		return 0, errors.New("the max size of " + fmt.Sprint(config.MaxSize) + " bytes can not be met for " + filepath.Base(rendition.OutputFile) + ", it would need a video bitrate of " +
+			fmt.Sprint(bitrate/1000) + "k which is below the minimum of " + fmt.Sprint(config.MinBitrate/1000) + "k")
+	}
+	return bitrate, nil
+}
+
+// ladderBitrate scales -target-bitrate by the pixel count of the streaming rendition, the highest rendition of the
+// ladder gets the full bitrate.
+func ladderBitrate(config config.Data, rendition config.Rendition) int64 {
+	top := int64(0)
+	for _, r := range config.Renditions {
+		top = max(top, r.Height)
+	}
+	if rendition.Height <= 0 || top <= 0 {
+		return config.TargetBitrate
+	}
+	scale := float64(rendition.Height*rendition.Height) / float64(top*top)
+	return max(int64(float64(config.TargetBitrate)*scale), config.MinBitrate)
+}
+
+// EncodeTwoPass encodes the input with the given video bitrate in two passes into the rendition output file.
+func EncodeTwoPass(input Video, config config.Data, rendition config.Rendition, bitrate int64) error {
+	rendition.Encoding.Bitrate = bitrate
+	encoding := rendition.Encoding
+	passLog := path.Join(config.WorkingDir, "twopass_"+filepath.Base(rendition.OutputFile))
+	if encoding.Codec == "av1" {
+		// The svt-av1 wrapper of ffmpeg does not support two passes, a single vbr pass is the best we can do.
+		log.Println("Two-pass encoding is not supported for av1, using a single pass")
+		return encodePass(input, config, rendition, []string{}, rendition.OutputFile)
+	}
+	err := encodePass(input, config, rendition, passArgs(encoding, 1, passLog), os.DevNull)
+	if err != nil {
+		return err
+	}
+	return encodePass(input, config, rendition, passArgs(encoding, 2, passLog), rendition.OutputFile)
+}
+
+// CheckMaxSize returns an error if the final output file is larger than -max-size, it runs after all streams,
+// captions and attachments are muxed.
+func CheckMaxSize(config config.Data, outputFile string) error {
+	if config.MaxSize == 0 {
+		return nil
+	}
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		return err
+	}
+	if info.Size() > config.MaxSize {
+		return errors.New("the output " + filepath.Base(outputFile) + " (" + fmt.Sprint(info.Size()) + " bytes) exceeds the max size of " + fmt.Sprint(config.MaxSize) + " bytes")
+	}
+	return nil
+}
+
+func attachmentSize(config config.Data, rendition config.Rendition) int64 {
+	if filepath.Ext(rendition.OutputFile) != ".mkv" {
+		return 0
+	}
+	size := int64(0)
+	for _, attachment := range mkvAttachments(config) {
+		info, err := os.Stat(attachment)
+		if err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// encodePass runs one encoding pass, the first pass (output os.DevNull) only analyses the video.
+func encodePass(input Video, config config.Data, rendition config.Rendition, pass []string, outputFile string) error {
+	withAudio := outputFile != os.DevNull
+	cmd := []string{"-hide_banner", "-loglevel", "error", "-threads", config.ThreadCount, "-i", input.VideoPath, "-map", "0:v:0"}
+	if withAudio {
+		cmd = append(cmd, "-map", "0:a?")
+	}
+	cmd = append(cmd, ScaleArgs(rendition)...)
+	cmd = append(cmd, VideoEncodeArgs(rendition.Encoding, rendition.Encoding.Tune)...)
+	cmd = append(cmd, pass...)
+	if withAudio {
+		cmd = append(cmd, AudioEncodeArgs(rendition.OutputFile)...)
+		cmd = append(cmd, MovFlagsArgs(config, rendition.OutputFile)...)
+	} else {
+		cmd = append(cmd, "-an", "-f", "null")
+	}
+	cmd = append(cmd, "-y", outputFile)
+	_, err := util.ExecuteCommand("ffmpeg", cmd...).Output()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func passArgs(encoding config.Encoding, pass int, passLog string) []string {
+	if encoding.Codec == "h265" {
+		return []string{"-x265-params", "pass=" + fmt.Sprint(pass) + ":stats=" + passLog + ".log"}
+	}
+	return []string{"-pass", fmt.Sprint(pass), "-passlogfile", passLog}
+}
@@ -16,6 +16,7 @@ type Video struct {
 	Duration    float64
 	Width       float64
 	Height      float64
+	Codec       string
 	IsOnlyAudio bool
 }
 
@@ -27,10 +28,11 @@ type ParseInfoStream struct {
 	Width    int    `json:"width"`
 	Heigth   int    `json:"height"`
 	Duration string `json:"duration"`
+	Codec    string `json:"codec_name"`
 }
 
 func GetVideoInfo(videofile string) (Video, error) {
-	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height,duration", "-of", "json", videofile).Output()
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height,duration,codec_name", "-of", "json", videofile).Output()
 	if err != nil {
 		return Video{}, err
 	}
@@ -48,6 +50,7 @@ func GetVideoInfo(videofile string) (Video, error) {
 		Duration:    duration,
 		Width:       float64(info.Streams[0].Width),
 		Height:      float64(info.Streams[0].Heigth),
+		Codec:       info.Streams[0].Codec,
 		IsOnlyAudio: false,
 	}, nil
 }
@@ -75,3 +78,12 @@ func (v *Video) IsAllWhiteVideo(duration int, config config.Data) bool {
 	}
 	return math.Abs(float64(duration)-durationBlack) < 1.0
 }
+
+// HasAudio reports whether the file contains at least one audio stream.
+func HasAudio(videofile string) bool {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "a", "-show_entries", "stream=index", "-of", "csv=p=0", videofile).Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(out)) != ""
+}
@@ -0,0 +1,201 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type cue struct {
+	Start    float64
+	End      float64
+	Settings string
+	Text     string
+}
+
+type vttFile struct {
+	Styles []string
+	Cues   []cue
+}
+
+// cueMergeGap is the maximum gap between two cues with the same text which are merged into one.
+const cueMergeGap = 0.1
+
+// CleanupCaption applies the configured offset and cleanup to the WebVTT source and writes the result to the target.
+func CleanupCaption(source string, target string, config config.Data) error {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	vtt, err := parseVTT(string(content))
+	if err != nil {
+		return err
+	}
+	vtt.shift(config.CaptionOffset)
+	if config.CaptionCleanup {
+		vtt.cleanup()
+	}
+	if config.CaptionMaxDuration > 0 {
+		for i := range vtt.Cues {
+			vtt.Cues[i].End = math.Min(vtt.Cues[i].End, vtt.Cues[i].Start+config.CaptionMaxDuration)
+		}
+	}
+	if config.CaptionMaxLine > 0 {
+		for i := range vtt.Cues {
+			vtt.Cues[i].Text = wrapText(vtt.Cues[i].Text, config.CaptionMaxLine)
+		}
+	}
+	return os.WriteFile(target, []byte(vtt.String()), 0o644)
+}
+
+func parseVTT(content string) (vttFile, error) {
+	content = strings.ReplaceAll(strings.TrimPrefix(content, "\ufeff"), "\r\n", "\n")
+	blocks := strings.Split(content, "\n\n")
+	if len(blocks) == 0 || !strings.HasPrefix(blocks[0], "WEBVTT") {
+		return vttFile{}, errors.New("caption is not a WebVTT file")
+	}
+	vtt := vttFile{}
+	for _, block := range blocks[1:] {
+		block = strings.Trim(block, "\n")
+		if block == "" || strings.HasPrefix(block, "NOTE") {
+			continue
+		}
+		if strings.HasPrefix(block, "STYLE") || strings.HasPrefix(block, "REGION") {
+			vtt.Styles = append(vtt.Styles, block)
+			continue
+		}
+		lines := strings.Split(block, "\n")
+		// The cue identifier is optional, the timing line is the first one containing the arrow.
+		for len(lines) > 0 && !strings.Contains(lines[0], "-->") {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		timing := strings.Fields(lines[0])
+		if len(timing) < 3 {
+			continue
+		}
+		start, err := parseVTTTimestamp(timing[0])
+		if err != nil {
+			continue
+		}
+		end, err := parseVTTTimestamp(timing[2])
+		if err != nil {
+			continue
+		}
+		vtt.Cues = append(vtt.Cues, cue{start, end, strings.Join(timing[3:], " "), strings.Join(lines[1:], "\n")})
+	}
+	return vtt, nil
+}
+
+func (v *vttFile) shift(offset float64) {
+	if offset == 0 {
+		return
+	}
+	var cues []cue
+	for _, c := range v.Cues {
+		c.Start = math.Max(0, c.Start+offset)
+		c.End += offset
+		if c.End > c.Start {
+			cues = append(cues, c)
+		}
+	}
+	v.Cues = cues
+}
+
+// cleanup drops empty and duplicate cues and merges overlapping ones. A cue which repeats or continues the text of the
+// previous one is merged into it, otherwise the previous cue ends when the next one starts.
+func (v *vttFile) cleanup() {
+	sort.SliceStable(v.Cues, func(i, j int) bool {
+		return v.Cues[i].Start < v.Cues[j].Start
+	})
+	var cues []cue
+	for _, c := range v.Cues {
+		c.Text = strings.TrimSpace(c.Text)
+		if c.Text == "" || c.End <= c.Start {
+			continue
+		}
+		if len(cues) > 0 {
+			last := &cues[len(cues)-1]
+			sameText := normalizeCueText(last.Text) == normalizeCueText(c.Text)
+			continued := strings.HasPrefix(normalizeCueText(c.Text), normalizeCueText(last.Text))
+			if (sameText || continued) && c.Start <= last.End+cueMergeGap {
+				last.End = math.Max(last.End, c.End)
+				last.Text = c.Text
+				continue
+			}
+			if c.Start < last.End {
+				last.End = c.Start
+				if last.End <= last.Start {
+					cues = cues[:len(cues)-1]
+				}
+			}
+		}
+		cues = append(cues, c)
+	}
+	v.Cues = cues
+}
+
+func (v *vttFile) String() string {
+	content := "WEBVTT\n\n"
+	for _, style := range v.Styles {
+		content += style + "\n\n"
+	}
+	for _, c := range v.Cues {
+		content += formatVTTTimestamp(c.Start) + " --> " + formatVTTTimestamp(c.End)
+		if c.Settings != "" {
+			content += " " + c.Settings
+		}
+		content += "\n" + c.Text + "\n\n"
+	}
+	return content
+}
+
+func normalizeCueText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// wrapText rewraps the cue text so no line is longer than maxLine characters, words are not split.
+func wrapText(text string, maxLine int) string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > maxLine {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseVTTTimestamp(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	seconds := 0.0
+	for _, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+		seconds = seconds*60 + number
+	}
+	return seconds, nil
+}
+
+func formatVTTTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
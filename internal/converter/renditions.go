@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"fmt"
+	"github.com/cli-ish/bbb-video-converter/internal/config"
+	"github.com/cli-ish/bbb-video-converter/internal/converter/modules"
+	"github.com/cli-ish/bbb-video-converter/internal/converter/modules/presentation"
+	"log"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeRenditions encodes the rendered video into all renditions, they run in parallel within the thread budget.
+func writeRenditions(fullVideo modules.Video, config config.Data, bitrates []int64, captions []modules.Caption, metadataFile string, posterFile string) error {
+	threads, err := strconv.Atoi(config.ThreadCount)
+	if err != nil || threads < 1 {
+		threads = 1
+	}
+	workers := len(config.Renditions)
+	if workers > threads {
+		workers = threads
+	}
+	renditionConfig := config
+	renditionConfig.ThreadCount = fmt.Sprint(threads / workers)
+	var wg sync.WaitGroup
+	var mutex = &sync.Mutex{}
+	var firstErr error
+	queue := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				start := time.Now()
+				err := writeRendition(fullVideo, renditionConfig, config.Renditions[i], bitrates[i], captions, metadataFile, posterFile)
+				if err != nil {
+					mutex.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mutex.Unlock()
+					continue
+				}
+				end := time.Now().Sub(start)
+				log.Println("Writing " + config.Renditions[i].OutputFile + " took: " + fmt.Sprint(end))
+			}
+		}()
+	}
+	for i := range config.Renditions {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+// capRenditions limits the renditions to the height of the rendered video, upscaling only makes the output larger.
+// Streaming renditions which end up with the same height and codec as an earlier one are dropped from the ladder.
+func capRenditions(config config.Data, bitrates []int64, fullVideo modules.Video) ([]config.Rendition, []int64) {
+	source := int64(fullVideo.Height) / 2 * 2
+	if source <= 0 {
+		return config.Renditions, bitrates
+	}
+	renditions := config.Renditions[:0:0]
+	var renditionBitrates []int64
+	seen := map[string]bool{}
+	for i, rendition := range config.Renditions {
+		if rendition.Height > source {
+			log.Println("The rendered video is only " + fmt.Sprint(source) + "p high, " + filepath.Base(rendition.OutputFile) + " is not upscaled")
+			rendition.Height = source
+		}
+		key := rendition.Encoding.Codec + ":" + fmt.Sprint(rendition.Height)
+		if config.IsStreaming() && seen[key] {
+			log.Println("Skipping " + filepath.Base(rendition.OutputFile) + ", the ladder already has a " + fmt.Sprint(rendition.Height) + "p " + rendition.Encoding.Codec + " rendition")
+			continue
+		}
+		seen[key] = true
+		renditions = append(renditions, rendition)
+		renditionBitrates = append(renditionBitrates, bitrates[i])
+	}
+	return renditions, renditionBitrates
+}
+
+func writeRendition(fullVideo modules.Video, config config.Data, rendition config.Rendition, bitrate int64, captions []modules.Caption, metadataFile string, posterFile string) error {
+	if config.Container() == "m3u8" {
+		return modules.WriteHLSVariant(fullVideo, config, rendition, bitrate)
+	}
+	if strings.HasSuffix(rendition.OutputFile, ".mkv") {
+		// Encode into a temporary mp4 first, the matroska muxing adds the captions, chapters and attachments.
+		encoded := fullVideo
+		if bitrate > 0 || rendition.Height > 0 {
+			encodedRendition := rendition
+			encodedRendition.OutputFile = path.Join(config.WorkingDir, "rendition_"+filepath.Base(rendition.OutputFile)+".mp4")
+			err := encodeRendition(fullVideo, config, encodedRendition, bitrate)
+			if err != nil {
+				return err
+			}
+			encoded = modules.Video{VideoPath: encodedRendition.OutputFile}
+		}
+		err := modules.WriteMKV(encoded, captions, metadataFile, posterFile, config, rendition)
+		if err != nil {
+			return err
+		}
+		return modules.CheckMaxSize(config, rendition.OutputFile)
+	}
+	err := encodeRendition(fullVideo, config, rendition, bitrate)
+	if err != nil {
+		return err
+	}
+	if len(captions) > 0 {
+		err = modules.AddCaption(captions, config, rendition.OutputFile)
+		if err != nil {
+			// Todo: should we really exit here if the caption thrown an error ?
+			return err
+		}
+		log.Println("Added caption data to " + rendition.OutputFile)
+	}
+	if posterFile != "" && strings.HasSuffix(rendition.OutputFile, ".mp4") {
+		err = modules.AddCoverArt(posterFile, config, rendition.OutputFile)
+		if err != nil {
+			return err
+		}
+	}
+	return modules.CheckMaxSize(config, rendition.OutputFile)
+}
+
+func encodeRendition(fullVideo modules.Video, config config.Data, rendition config.Rendition, bitrate int64) error {
+	if bitrate > 0 {
+		log.Println("Encoding " + rendition.OutputFile + " in two passes with " + fmt.Sprint(bitrate/1000) + "k video bitrate")
+		return modules.EncodeTwoPass(fullVideo, config, rendition, bitrate)
+	}
+	if rendition.Height > 0 || strings.HasSuffix(rendition.OutputFile, ".webm") {
+		return modules.ProcessToEndExtension(fullVideo, config, rendition)
+	}
+	if len(modules.MovFlagsArgs(config, rendition.OutputFile)) > 0 {
+		return modules.RemuxOutput(fullVideo, config, rendition)
+	}
+	return copyFile(fullVideo.VideoPath, rendition.OutputFile)
+}
+
+// writeOutputMetadata writes the tags and slide chapters as ffmpeg metadata file, it is only needed for mkv outputs.
+func writeOutputMetadata(config config.Data, duration int) string {
+	needed := false
+	for _, rendition := range config.Renditions {
+		needed = needed || strings.HasSuffix(rendition.OutputFile, ".mkv")
+	}
+	if !needed {
+		return ""
+	}
+	tags, err := modules.GetMetadata(config)
+	if err != nil {
+		log.Println("Could not read the recording metadata, writing output without tags")
+	}
+	metadataFile := path.Join(config.WorkingDir, "output.ffmetadata")
+	timeline := presentation.GetSlideTimeline(config.RecordingDir, duration)
+	err = modules.WriteFFMetadata(metadataFile, tags, presentation.SlideChapters(timeline))
+	if err != nil {
+		log.Println("Could not write the output metadata, writing output without chapters")
+		return ""
+	}
+	return metadataFile
+}